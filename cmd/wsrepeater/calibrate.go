@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"wsrepeater/internal/archive"
+	"wsrepeater/internal/calibration"
+	"wsrepeater/internal/config"
+	"wsrepeater/internal/weather"
+)
+
+const calibrateUsage = `Usage: wsrepeater calibrate [flags] reference.csv
+
+Fits correction polynomials mapping archived station readings to reference
+readings, e.g. from a nearby reference pyranometer or a METAR station.
+
+The CSV needs a header row. Its first column is the time of each reading,
+as RFC 3339, "2006-01-02 15:04:05" in the station time zone, or Unix
+seconds. Every other column is named after the Ecowitt parameter it holds
+(tempf, humidity, baromrelin, solarradiation...), in the same units.
+
+The archive holds calibrated values, so the fitted stage is appended to the
+current pipeline of each parameter. The archive can't be opened while the
+server is running; stop it or point -data at a copy of archive.db.
+
+Flags:
+`
+
+// referenceReading is one row of the reference CSV.
+type referenceReading struct {
+	time   time.Time
+	values map[string]float64
+}
+
+func runCalibrate(args []string) {
+	flags := flag.NewFlagSet("calibrate", flag.ExitOnError)
+	dataDir := flags.String("data", config.GetString("DATA_DIR", "data"), "data directory holding archive.db")
+	degree := flags.Int("degree", 1, "polynomial degree, 1 for a linear fit")
+	tolerance := flags.Duration("tolerance", 2*time.Minute, "largest time difference between paired readings")
+	showResiduals := flags.Bool("residuals", false, "print the residual of every pair")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), calibrateUsage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	loc := config.StationTimezone()
+	keys, readings, err := readReferenceCSV(flags.Arg(0), loc)
+	if err != nil {
+		log.Fatalf("Failed to read reference data: %v", err)
+	}
+	if len(readings) == 0 {
+		log.Fatalf("No reference readings in %s", flags.Arg(0))
+	}
+
+	store, err := archive.Open(*dataDir, archive.Options{Location: loc})
+	if err != nil {
+		log.Fatalf("Failed to open archive (is the server running?): %v", err)
+	}
+	defer store.Close()
+
+	observations, err := store.Range(readings[0].time.Add(-*tolerance), readings[len(readings)-1].time.Add(*tolerance+time.Second))
+	if err != nil {
+		log.Fatalf("Failed to read archive: %v", err)
+	}
+
+	calibrator, err := calibration.Load()
+	if err != nil {
+		log.Fatalf("Failed to read current calibration: %v", err)
+	}
+	specs := make(map[string]string)
+	for _, p := range calibrator.Pipelines() {
+		specs[p.Key] = p.Spec
+	}
+
+	var suggestions []string
+	for _, key := range keys {
+		var station, reference []float64
+		var times []time.Time
+		for _, r := range readings {
+			v, ok := r.values[key]
+			if !ok {
+				continue
+			}
+			obs := nearest(observations, r.time, *tolerance)
+			if obs == nil {
+				continue
+			}
+			if s, ok := obs.Get(key); ok {
+				station = append(station, s)
+				reference = append(reference, v)
+				times = append(times, r.time)
+			}
+		}
+
+		fmt.Printf("%s: %d paired readings\n", key, len(station))
+		fit, err := calibration.FitPolynomial(station, reference, *degree)
+		if err != nil {
+			fmt.Printf("  cannot fit: %v\n\n", err)
+			continue
+		}
+
+		fmt.Printf("  fit:          %s\n", fit.Stage())
+		fmt.Printf("  R²:           %.4f\n", fit.R2)
+		fmt.Printf("  RMSE:         %.3f\n", fit.RMSE)
+		fmt.Printf("  bias:         %.3f\n", fit.Bias)
+		fmt.Printf("  max residual: %.3f\n", fit.MaxResidual)
+		if *showResiduals {
+			fmt.Printf("  %-20s %12s %12s %12s\n", "time", "station", "reference", "residual")
+			for i := range station {
+				fmt.Printf("  %-20s %12.3f %12.3f %12.3f\n", times[i].In(loc).Format("2006-01-02 15:04:05"), station[i], reference[i], fit.Residuals[i])
+			}
+		}
+		fmt.Println()
+
+		suggestions = append(suggestions, fmt.Sprintf("CALIBRATE_%s=%s", strings.ToUpper(key), calibration.AppendStage(specs[key], fit.Stage())))
+	}
+
+	if len(suggestions) > 0 {
+		fmt.Println("# Calibration config, paste into .env")
+		for _, s := range suggestions {
+			fmt.Println(s)
+		}
+	}
+}
+
+// readReferenceCSV returns the parameter columns of the CSV at path and its
+// readings, sorted by time.
+func readReferenceCSV(path string, loc *time.Location) ([]string, []referenceReading, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.TrimLeadingSpace = true
+
+	header, err := r.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading header: %v", err)
+	}
+	if len(header) < 2 {
+		return nil, nil, fmt.Errorf("header needs a time column and at least one parameter")
+	}
+	keys := make([]string, len(header)-1)
+	for i, name := range header[1:] {
+		keys[i] = strings.ToLower(strings.TrimSpace(name))
+		if !weather.IsKey(keys[i]) {
+			return nil, nil, fmt.Errorf("column %q is not an Ecowitt parameter", name)
+		}
+	}
+
+	var readings []referenceReading
+	for line := 2; ; line++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("line %d: %v", line, err)
+		}
+
+		t, err := parseReferenceTime(record[0], loc)
+		if err != nil {
+			return nil, nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		reading := referenceReading{time: t, values: make(map[string]float64)}
+		for i, raw := range record[1:] {
+			raw = strings.TrimSpace(raw)
+			if raw == "" || i >= len(keys) {
+				continue
+			}
+			v, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return nil, nil, fmt.Errorf("line %d: invalid %s %q", line, keys[i], raw)
+			}
+			reading.values[keys[i]] = v
+		}
+		readings = append(readings, reading)
+	}
+
+	sort.Slice(readings, func(i, j int) bool { return readings[i].time.Before(readings[j].time) })
+	return keys, readings, nil
+}
+
+func parseReferenceTime(raw string, loc *time.Location) (time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04:05", raw, loc); err == nil {
+		return t, nil
+	}
+	if seconds, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("unrecognized time %q", raw)
+}
+
+// nearest returns the observation closest to t, if it is within tolerance.
+// observations must be sorted by time.
+func nearest(observations []*weather.Observation, t time.Time, tolerance time.Duration) *weather.Observation {
+	i := sort.Search(len(observations), func(i int) bool { return !observations[i].Time.Before(t) })
+
+	var best *weather.Observation
+	bestDiff := tolerance + 1
+	for _, j := range []int{i - 1, i} {
+		if j < 0 || j >= len(observations) {
+			continue
+		}
+		diff := observations[j].Time.Sub(t)
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff < bestDiff {
+			best, bestDiff = observations[j], diff
+		}
+	}
+	return best
+}
@@ -7,22 +7,82 @@ import (
 	"net/http"
 	"os"
 	"time"
+	"wsrepeater/internal/archive"
+	"wsrepeater/internal/calibration"
 	"wsrepeater/internal/config"
 	"wsrepeater/internal/handlers"
 	"wsrepeater/internal/middleware"
+	"wsrepeater/internal/queue"
+	"wsrepeater/internal/sinks"
 )
 
 //go:embed static/*
 var staticFiles embed.FS
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "calibrate" {
+		config.LoadEnv()
+		runCalibrate(os.Args[2:])
+		return
+	}
+
 	config.LoadConfig()
 
-	stats := middleware.NewStats()
+	calibrator, err := calibration.Load()
+	if err != nil {
+		log.Fatalf("Failed to configure calibration: %v", err)
+	}
+	for _, p := range calibrator.Pipelines() {
+		log.Printf("Calibrating %s: %s", p.Key, p.Spec)
+	}
+	handlers.SetCalibrator(calibrator)
+
+	dataDir := config.GetString("DATA_DIR", "data")
+
+	store, err := archive.Open(dataDir, archive.Options{
+		Location:            config.StationTimezone(),
+		RawRetention:        config.GetDays("ARCHIVE_RETENTION_DAYS", 30),
+		FiveMinuteRetention: config.GetDays("ROLLUP_5M_RETENTION_DAYS", 90),
+		HourlyRetention:     config.GetDays("ROLLUP_HOURLY_RETENTION_DAYS", 730),
+		DailyRetention:      config.GetDays("ROLLUP_DAILY_RETENTION_DAYS", 0),
+		RollupDelay:         config.GetDuration("ROLLUP_DELAY", 2*time.Minute),
+		DailyHook:           handlers.AccumulateAgro,
+	})
+	if err != nil {
+		log.Fatalf("Failed to open archive: %v", err)
+	}
+	defer store.Close()
+	handlers.SetArchive(store)
+
+	enabledSinks, err := sinks.Enabled()
+	if err != nil {
+		log.Fatalf("Failed to configure sinks: %v", err)
+	}
+	uploadQueue, err := queue.Open(dataDir, queue.Options{
+		MaxAge:        config.GetDuration("QUEUE_MAX_AGE", 24*time.Hour),
+		MaxAttempts:   config.GetInt("QUEUE_MAX_ATTEMPTS", 0),
+		MinBackoff:    config.GetDuration("QUEUE_MIN_BACKOFF", 10*time.Second),
+		MaxBackoff:    config.GetDuration("QUEUE_MAX_BACKOFF", 10*time.Minute),
+		DeadRetention: config.GetDays("QUEUE_DEAD_RETENTION_DAYS", 7),
+	})
+	if err != nil {
+		log.Fatalf("Failed to open upload queue: %v", err)
+	}
+	defer uploadQueue.Close()
+
+	overflow, err := queue.ParseOverflow(config.GetString("QUEUE_OVERFLOW", "drop-oldest"))
+	if err != nil {
+		log.Fatalf("Invalid QUEUE_OVERFLOW: %v", err)
+	}
+	dispatcher := sinks.NewDispatcher(enabledSinks, uploadQueue, config.GetInt("QUEUE_CAPACITY", 10000), overflow)
+	handlers.SetDispatcher(dispatcher)
+
+	stats := middleware.NewStats(dispatcher)
 
 	cacheDurations := map[string]time.Duration{
 		"/":                     120 * time.Minute,
 		"/stats":                0 * time.Second,
+		"/stats/sinks":          0 * time.Second,
 		"/latest":               1 * time.Minute,
 		"/weekly":               5 * time.Minute,
 		"/moon":                 20 * time.Minute,
@@ -30,6 +90,11 @@ func main() {
 		"/rss/nb10_e.xml":       5 * time.Minute,
 		"/rss/nb16_e.xml":       5 * time.Minute,
 		"/rss/city/nb-17_e.xml": 5 * time.Minute,
+		"/forecast/local":       5 * time.Minute,
+		"/rain":                 1 * time.Minute,
+		"/agro":                 15 * time.Minute,
+		"/wind":                 0 * time.Second,
+		"/wind/rose":            15 * time.Minute,
 	}
 
 	defaultCacheDuration := 1 * time.Minute
@@ -45,13 +110,21 @@ func main() {
 	mux.HandleFunc("/weekly", handlers.ProxyWUHistory)                           // Weekly observations from WeatherUnderground
 	mux.HandleFunc("/moon", handlers.ProxyMoon)                                  // Moon phase logic
 	mux.HandleFunc("/sunrise-sunset", handlers.ProxySunriseSunset)               // Sunrise and sunset times
+	mux.HandleFunc("/forecast/local", handlers.GetLocalForecast)                 // Forecast from the station's own barometer
+	mux.HandleFunc("/rain", handlers.GetRain)                                    // Rain totals, events and dry spells
+	mux.HandleFunc("/agro", handlers.GetAgro)                                    // Evapotranspiration, degree days and chill hours
+	mux.HandleFunc("/wind", handlers.GetWind)                                    // Current wind with 2- and 10-minute averages
+	mux.HandleFunc("/wind/rose", handlers.GetWindRose)                           // Wind rose over a period
 	mux.Handle("/", http.FileServer(getStaticFiles()))                           // Serve static files for the frontend
 	mux.HandleFunc("/stats", stats.ServeStats)
+	mux.HandleFunc("/stats/sinks", stats.ServeSinkStatus) // Upload status of each sink as JSON
 
 	handler := middleware.GzipMiddleware(stats.Middleware(middleware.CacheControl(cacheDurations,
 		defaultCacheDuration, staticCacheDuration)(mux)))
 
-	go handlers.StartWorkerPool()
+	dispatcher.Start()
+	go store.StartPruner(time.Hour)
+	go store.StartRollups(time.Minute)
 	go handlers.StartWUPrefetcher()
 	go handlers.StartMoonPrefetcher()
 	go handlers.StartRSSPrefetcher()
@@ -174,37 +174,15 @@ document.addEventListener("DOMContentLoaded", function () {
                 const dayTimeElement = document.getElementById("day-time");
                 dayTimeElement.innerHTML = `${formattedDate}, ${formattedTime}`;
 
+                // Humidex and wind chill are computed by the server, so they
+                // match what is uploaded to the weather networks
                 let additionalInfo = "";
-                if (tempC > 20) {
-                    // Calculate dew point
-                    const tempFloat = parseFloat(tempC);
-                    const dewPointK =
-                        (243.04 *
-                            (Math.log(humidity / 100) +
-                                (17.625 * tempFloat) / (243.04 + tempFloat))) /
-                            (17.625 -
-                                (Math.log(humidity / 100) +
-                                    (17.625 * tempFloat) /
-                                        (243.04 + tempFloat))) +
-                        273.15;
-
-                    // Calculate humidex
-                    const humidex =
-                        tempFloat +
-                        0.5555 *
-                            (6.11 *
-                                Math.exp(
-                                    5417.753 * (1 / 273.16 - 1 / dewPointK),
-                                ) -
-                                10);
+                if (tempC > 20 && data.humidex !== undefined) {
+                    const humidex = parseFloat(data.humidex);
                     additionalInfo = `<span style="color:#ff5555">Humidex: <span>${humidex.toFixed(1)}</span> °C</span>`;
-                } else if (tempC <= 10 && windSpeedKmH > 4.8) {
-                    // Calculate wind chill
+                } else if (data.windchillf !== undefined) {
                     const windChill =
-                        13.12 +
-                        0.6215 * tempC -
-                        11.37 * Math.pow(windSpeedKmH, 0.16) +
-                        0.3965 * tempC * Math.pow(windSpeedKmH, 0.16);
+                        ((parseFloat(data.windchillf) - 32) * 5) / 9;
                     additionalInfo = `<span style="color:#6272a4">Wind Chill: <span>${windChill.toFixed(1)}</span> °C</span>`;
                 }
 
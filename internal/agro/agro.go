@@ -0,0 +1,124 @@
+// Package agro computes the agricultural indices a garden or irrigation
+// schedule relies on: reference evapotranspiration, growing degree days,
+// heating and cooling degree days and chill hours.
+package agro
+
+import "math"
+
+const (
+	// solarConstantMJ is the solar constant in MJ/(m²·min).
+	solarConstantMJ = 0.0820
+	// stefanBoltzmannDaily is σ in MJ/(K⁴·m²·day).
+	stefanBoltzmannDaily = 4.903e-9
+	albedo               = 0.23
+
+	// Chill hours count the hours between 0 and 7.2 °C (32–45 °F).
+	chillMinC = 0.0
+	chillMaxC = 7.2
+)
+
+// Day holds the daily summary FAO-56 needs. Optional inputs are nil when
+// the station didn't report them.
+type Day struct {
+	DayOfYear   int
+	TempMaxC    float64
+	TempMinC    float64
+	HumidityMax *float64 // %
+	HumidityMin *float64 // %
+	WindMs      *float64 // mean speed at the sensor height
+	SolarWm2    *float64 // mean irradiance over the whole day
+	PressureHPa *float64 // mean station pressure
+}
+
+// ET0 returns the FAO-56 Penman-Monteith reference evapotranspiration of a
+// short grass crop, in mm/day, at latitude lat with the anemometer at
+// windHeight metres. It needs solar radiation, humidity and wind.
+func ET0(d Day, lat, windHeight float64) (float64, bool) {
+	if d.SolarWm2 == nil || d.HumidityMax == nil || d.HumidityMin == nil || d.WindMs == nil {
+		return 0, false
+	}
+
+	tMean := (d.TempMaxC + d.TempMinC) / 2
+
+	// Atmospheric pressure in kPa, and the elevation it implies
+	pressure := 101.3
+	if d.PressureHPa != nil {
+		pressure = *d.PressureHPa / 10
+	}
+	elevation := (1 - math.Pow(pressure/101.3, 1/5.26)) * 293 / 0.0065
+	gamma := 0.000665 * pressure
+
+	delta := 4098 * saturationVaporPressure(tMean) / math.Pow(tMean+237.3, 2)
+	es := (saturationVaporPressure(d.TempMaxC) + saturationVaporPressure(d.TempMinC)) / 2
+	ea := (saturationVaporPressure(d.TempMinC)**d.HumidityMax/100 + saturationVaporPressure(d.TempMaxC)**d.HumidityMin/100) / 2
+
+	u2 := *d.WindMs
+	if windHeight > 0 && windHeight != 2 {
+		u2 *= 4.87 / math.Log(67.8*windHeight-5.42)
+	}
+
+	// Radiation balance in MJ/(m²·day)
+	rs := *d.SolarWm2 * 0.0864
+	ra := ExtraterrestrialRadiation(lat, d.DayOfYear)
+	rso := (0.75 + 2e-5*elevation) * ra
+	ratio := 1.0
+	if rso > 0 {
+		ratio = math.Min(1, rs/rso)
+	}
+	rns := (1 - albedo) * rs
+	rnl := stefanBoltzmannDaily * (math.Pow(d.TempMaxC+273.16, 4) + math.Pow(d.TempMinC+273.16, 4)) / 2 *
+		(0.34 - 0.14*math.Sqrt(math.Max(0, ea))) * (1.35*ratio - 0.35)
+	rn := rns - rnl
+
+	et0 := (0.408*delta*rn + gamma*900/(tMean+273)*u2*(es-ea)) / (delta + gamma*(1+0.34*u2))
+	return math.Max(0, et0), true
+}
+
+// ExtraterrestrialRadiation returns the daily radiation at the top of the
+// atmosphere, Ra, in MJ/(m²·day) (FAO-56 equation 21).
+func ExtraterrestrialRadiation(lat float64, dayOfYear int) float64 {
+	phi := lat * math.Pi / 180
+	j := float64(dayOfYear)
+
+	dr := 1 + 0.033*math.Cos(2*math.Pi/365*j)
+	decl := 0.409 * math.Sin(2*math.Pi/365*j-1.39)
+	ws := math.Acos(math.Max(-1, math.Min(1, -math.Tan(phi)*math.Tan(decl))))
+
+	return 24 * 60 / math.Pi * solarConstantMJ * dr *
+		(ws*math.Sin(phi)*math.Sin(decl) + math.Cos(phi)*math.Cos(decl)*math.Sin(ws))
+}
+
+// saturationVaporPressure returns e°(T) in kPa (FAO-56 equation 11).
+func saturationVaporPressure(tempC float64) float64 {
+	return 0.6108 * math.Exp(17.27*tempC/(tempC+237.3))
+}
+
+// GrowingDegreeDays returns the day's growing degree days above base, with
+// the maximum capped at upper and the minimum raised to base (the modified
+// averaging method).
+func GrowingDegreeDays(tempMaxC, tempMinC, base, upper float64) float64 {
+	tMax := math.Min(tempMaxC, upper)
+	tMin := math.Max(tempMinC, base)
+	if tMax < tMin {
+		tMax = tMin
+	}
+	return math.Max(0, (tMax+tMin)/2-base)
+}
+
+// HeatingDegreeDays returns how far the day's mean temperature fell below
+// base.
+func HeatingDegreeDays(tempMaxC, tempMinC, base float64) float64 {
+	return math.Max(0, base-(tempMaxC+tempMinC)/2)
+}
+
+// CoolingDegreeDays returns how far the day's mean temperature rose above
+// base.
+func CoolingDegreeDays(tempMaxC, tempMinC, base float64) float64 {
+	return math.Max(0, (tempMaxC+tempMinC)/2-base)
+}
+
+// IsChillHour reports whether an hour with mean temperature tempC counts
+// towards the chill requirement of fruit trees.
+func IsChillHour(tempC float64) bool {
+	return tempC >= chillMinC && tempC <= chillMaxC
+}
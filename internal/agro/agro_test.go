@@ -0,0 +1,95 @@
+package agro
+
+import (
+	"math"
+	"testing"
+)
+
+func float(v float64) *float64 {
+	return &v
+}
+
+// TestET0 checks FAO-56 example 18: Brussels (50°48'N, 100 m) on 6 July.
+func TestET0(t *testing.T) {
+	day := Day{
+		DayOfYear:   187,
+		TempMaxC:    21.5,
+		TempMinC:    12.3,
+		HumidityMax: float(84),
+		HumidityMin: float(63),
+		WindMs:      float(2.078),
+		SolarWm2:    float(22.07 / 0.0864),
+		PressureHPa: float(1001),
+	}
+
+	et0, ok := ET0(day, 50.8, 2)
+	if !ok {
+		t.Fatal("ET0 not computed")
+	}
+	if math.Abs(et0-3.9) > 0.05 {
+		t.Errorf("ET0 = %.2f mm/day, want 3.9", et0)
+	}
+
+	// The same wind measured at 10 m
+	day.WindMs = float(2.78)
+	if et0, _ := ET0(day, 50.8, 10); math.Abs(et0-3.9) > 0.05 {
+		t.Errorf("ET0 with a 10 m anemometer = %.2f mm/day, want 3.9", et0)
+	}
+
+	day.WindMs = nil
+	if _, ok := ET0(day, 50.8, 2); ok {
+		t.Error("ET0 computed without wind")
+	}
+}
+
+// TestExtraterrestrialRadiation checks FAO-56 example 8: 20°S on 3 September.
+func TestExtraterrestrialRadiation(t *testing.T) {
+	if ra := ExtraterrestrialRadiation(-20, 246); math.Abs(ra-32.2) > 0.1 {
+		t.Errorf("Ra = %.2f MJ/m²/day, want 32.2", ra)
+	}
+}
+
+func TestDegreeDays(t *testing.T) {
+	tests := []struct {
+		name             string
+		tempMax, tempMin float64
+		gdd, hdd, cdd    float64
+	}{
+		{"mild", 24, 12, 8, 0, 0},
+		{"minimum below base", 20, 4, 5, 6, 0},
+		{"maximum above cap", 36, 20, 15, 0, 10},
+		{"cold", 8, -2, 0, 15, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GrowingDegreeDays(tt.tempMax, tt.tempMin, 10, 30); got != tt.gdd {
+				t.Errorf("GDD = %v, want %v", got, tt.gdd)
+			}
+			if got := HeatingDegreeDays(tt.tempMax, tt.tempMin, 18); got != tt.hdd {
+				t.Errorf("HDD = %v, want %v", got, tt.hdd)
+			}
+			if got := CoolingDegreeDays(tt.tempMax, tt.tempMin, 18); got != tt.cdd {
+				t.Errorf("CDD = %v, want %v", got, tt.cdd)
+			}
+		})
+	}
+}
+
+func TestIsChillHour(t *testing.T) {
+	tests := []struct {
+		tempC float64
+		want  bool
+	}{
+		{-0.1, false},
+		{0, true},
+		{5, true},
+		{7.2, true},
+		{7.3, false},
+	}
+	for _, tt := range tests {
+		if got := IsChillHour(tt.tempC); got != tt.want {
+			t.Errorf("IsChillHour(%v) = %v, want %v", tt.tempC, got, tt.want)
+		}
+	}
+}
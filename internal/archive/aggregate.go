@@ -0,0 +1,198 @@
+package archive
+
+import (
+	"math"
+	"time"
+	"wsrepeater/internal/rain"
+	"wsrepeater/internal/weather"
+)
+
+// DewPointKey is the key under which aggregates carry the dew point. Like the
+// other derived quantities, it is computed from the observation rather than
+// reported by the gateway.
+const DewPointKey = "dewptf"
+
+// RainKey is the key under which aggregates carry the rain that fell during
+// the interval, in inches, as the Sum of the increments of the console's
+// counters. Unlike the counters themselves, it doesn't depend on when the
+// console resets them.
+const RainKey = "rainfallin"
+
+// Stat summarizes one field over an aggregation interval. It keeps enough to
+// merge two stats exactly, so coarser tiers can be built from finer ones.
+// Cumulative counters such as dailyrainin are read through Max and Last.
+type Stat struct {
+	Count int     `json:"n"`
+	Sum   float64 `json:"sum"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	First float64 `json:"first"`
+	Last  float64 `json:"last"`
+}
+
+func (s *Stat) add(v float64) {
+	if s.Count == 0 {
+		s.Min, s.Max, s.First = v, v, v
+	}
+	s.Min = math.Min(s.Min, v)
+	s.Max = math.Max(s.Max, v)
+	s.Last = v
+	s.Sum += v
+	s.Count++
+}
+
+func (s *Stat) merge(o *Stat) {
+	if o.Count == 0 {
+		return
+	}
+	if s.Count == 0 {
+		*s = *o
+		return
+	}
+	s.Min = math.Min(s.Min, o.Min)
+	s.Max = math.Max(s.Max, o.Max)
+	s.Last = o.Last
+	s.Sum += o.Sum
+	s.Count += o.Count
+}
+
+// Avg returns the mean of the samples.
+func (s *Stat) Avg() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Sum / float64(s.Count)
+}
+
+// Wind holds the components needed to vector-average wind direction and
+// speed. The unit components give a direction even when it was calm.
+type Wind struct {
+	Count int     `json:"n"`
+	U     float64 `json:"u"`
+	V     float64 `json:"v"`
+	UnitU float64 `json:"uu"`
+	UnitV float64 `json:"uv"`
+}
+
+func (w *Wind) add(speed, dir float64) {
+	rad := dir * math.Pi / 180
+	w.U += speed * math.Sin(rad)
+	w.V += speed * math.Cos(rad)
+	w.UnitU += math.Sin(rad)
+	w.UnitV += math.Cos(rad)
+	w.Count++
+}
+
+func (w *Wind) merge(o *Wind) {
+	w.U += o.U
+	w.V += o.V
+	w.UnitU += o.UnitU
+	w.UnitV += o.UnitV
+	w.Count += o.Count
+}
+
+// Direction returns the vector-averaged wind direction in degrees.
+func (w *Wind) Direction() (float64, bool) {
+	if w.Count == 0 {
+		return 0, false
+	}
+	u, v := w.U, w.V
+	if math.Hypot(u, v) < 1e-9 {
+		u, v = w.UnitU, w.UnitV
+	}
+	dir := math.Mod(math.Atan2(u, v)*180/math.Pi+360, 360)
+	return dir, true
+}
+
+// Speed returns the vector-averaged wind speed in mph.
+func (w *Wind) Speed() (float64, bool) {
+	if w.Count == 0 {
+		return 0, false
+	}
+	return math.Hypot(w.U, w.V) / float64(w.Count), true
+}
+
+// Aggregate summarizes the observations of one rollup interval.
+type Aggregate struct {
+	Start  time.Time        `json:"start"`
+	End    time.Time        `json:"end"`
+	Count  int              `json:"count"`
+	Fields map[string]*Stat `json:"fields"`
+	Wind   Wind             `json:"wind"`
+}
+
+// NewAggregate returns an empty aggregate for the interval starting at start.
+func NewAggregate(start time.Time) *Aggregate {
+	return &Aggregate{Start: start, Fields: make(map[string]*Stat)}
+}
+
+// Add folds an observation into the aggregate.
+func (a *Aggregate) Add(obs *weather.Observation) {
+	for _, key := range weather.MeasurementKeys() {
+		if v, ok := obs.Get(key); ok {
+			a.stat(key).add(v)
+		}
+	}
+	derived := obs.Derived()
+	for _, key := range weather.DerivedKeys() {
+		if v, ok := derived.Get(key); ok {
+			a.stat(key).add(v)
+		}
+	}
+	if obs.WindSpeedMph != nil && obs.WindDir != nil {
+		a.Wind.add(*obs.WindSpeedMph, *obs.WindDir)
+	}
+	if obs.Time.After(a.End) {
+		a.End = obs.Time
+	}
+	a.Count++
+}
+
+// AddRain folds the rain that fell between prev, the observation before obs,
+// and obs into the aggregate. Nothing is counted without a previous
+// observation.
+func (a *Aggregate) AddRain(prev, obs *weather.Observation) {
+	if prev == nil {
+		return
+	}
+	a.stat(RainKey).add(rain.Delta(prev, obs))
+}
+
+// Set stores v, computed for the whole interval, under key in place of any
+// samples of it.
+func (a *Aggregate) Set(key string, v float64) {
+	s := &Stat{}
+	s.add(v)
+	a.Fields[key] = s
+}
+
+// Merge folds a finer aggregate into this one.
+func (a *Aggregate) Merge(o *Aggregate) {
+	for key, s := range o.Fields {
+		a.stat(key).merge(s)
+	}
+	a.Wind.merge(&o.Wind)
+	if o.End.After(a.End) {
+		a.End = o.End
+	}
+	a.Count += o.Count
+}
+
+// Stat returns the summary of the Ecowitt field key, or nil if no sample of
+// it fell in the interval.
+func (a *Aggregate) Stat(key string) *Stat {
+	s, ok := a.Fields[key]
+	if !ok || s.Count == 0 {
+		return nil
+	}
+	return s
+}
+
+func (a *Aggregate) stat(key string) *Stat {
+	s, ok := a.Fields[key]
+	if !ok {
+		s = &Stat{}
+		a.Fields[key] = s
+	}
+	return s
+}
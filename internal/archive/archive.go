@@ -0,0 +1,240 @@
+package archive
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+	"wsrepeater/internal/weather"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const fileName = "archive.db"
+
+var (
+	observationsBucket = []byte("observations")
+	metaBucket         = []byte("meta")
+)
+
+// Options configures an archive. Each retention is how long the raw
+// observations or the aggregates of a tier are kept; zero keeps them forever.
+type Options struct {
+	Location            *time.Location
+	RawRetention        time.Duration
+	FiveMinuteRetention time.Duration
+	HourlyRetention     time.Duration
+	DailyRetention      time.Duration
+	// RollupDelay holds five-minute intervals open for that long after they
+	// end, so observations that arrive late still make it into the rollups.
+	RollupDelay time.Duration
+	// DailyHook, if set, is given each daily aggregate and the hourly ones
+	// it was built from before the day is stored, to add the indices that
+	// are accumulated per day.
+	DailyHook func(day *Aggregate, hours []*Aggregate)
+}
+
+// Store is the on-disk archive of every report received from the gateway.
+// Observations and their rollups are kept in a single bbolt file, keyed by
+// their timestamp so that time ranges can be read with a cursor.
+type Store struct {
+	db   *bolt.DB
+	loc  *time.Location
+	opts Options
+}
+
+// Open opens (or creates) the archive in dir.
+func Open(dir string, opts Options) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("error creating data directory: %v", err)
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, fileName), 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("error opening archive: %v", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		buckets := [][]byte{observationsBucket, metaBucket}
+		for _, tier := range tiers {
+			buckets = append(buckets, tier.bucket())
+		}
+		for _, name := range buckets {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error initializing archive: %v", err)
+	}
+
+	loc := opts.Location
+	if loc == nil {
+		loc = time.Local
+	}
+
+	return &Store{db: db, loc: loc, opts: opts}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Put stores an observation. A second observation with the same timestamp
+// replaces the first.
+func (s *Store) Put(obs *weather.Observation) error {
+	value, err := json.Marshal(obs)
+	if err != nil {
+		return fmt.Errorf("error encoding observation: %v", err)
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(observationsBucket).Put(timeKey(obs.Time), value)
+	})
+}
+
+// Range returns the observations with from <= time < to, oldest first.
+func (s *Store) Range(from, to time.Time) ([]*weather.Observation, error) {
+	var observations []*weather.Observation
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(observationsBucket).Cursor()
+		end := timeKey(to)
+		for k, v := c.Seek(timeKey(from)); k != nil && bytes.Compare(k, end) < 0; k, v = c.Next() {
+			obs := &weather.Observation{}
+			if err := json.Unmarshal(v, obs); err != nil {
+				return fmt.Errorf("error decoding observation %x: %v", k, err)
+			}
+			observations = append(observations, obs)
+		}
+		return nil
+	})
+
+	return observations, err
+}
+
+// observationBefore returns the last observation of b stored before t, or nil
+// if there is none.
+func observationBefore(b *bolt.Bucket, t time.Time) (*weather.Observation, error) {
+	c := b.Cursor()
+	k, v := c.Seek(timeKey(t))
+	if k == nil {
+		k, v = c.Last()
+	} else {
+		k, v = c.Prev()
+	}
+	if k == nil {
+		return nil, nil
+	}
+
+	obs := &weather.Observation{}
+	if err := json.Unmarshal(v, obs); err != nil {
+		return nil, fmt.Errorf("error decoding observation %x: %v", k, err)
+	}
+	return obs, nil
+}
+
+// Latest returns the most recent archived observation, or nil if the
+// archive is empty.
+func (s *Store) Latest() (*weather.Observation, error) {
+	var obs *weather.Observation
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		_, v := tx.Bucket(observationsBucket).Cursor().Last()
+		if v == nil {
+			return nil
+		}
+		obs = &weather.Observation{}
+		return json.Unmarshal(v, obs)
+	})
+
+	return obs, err
+}
+
+// Prune deletes observations and aggregates older than their retention
+// period and returns how many were removed.
+func (s *Store) Prune(now time.Time) (int, error) {
+	retentions := map[string]time.Duration{
+		string(observationsBucket):  s.opts.RawRetention,
+		string(FiveMinute.bucket()): s.opts.FiveMinuteRetention,
+		string(Hourly.bucket()):     s.opts.HourlyRetention,
+		string(Daily.bucket()):      s.opts.DailyRetention,
+	}
+
+	removed := 0
+	for name, retention := range retentions {
+		if retention <= 0 {
+			continue
+		}
+		n, err := s.prune([]byte(name), now.Add(-retention))
+		if err != nil {
+			return removed, err
+		}
+		removed += n
+	}
+
+	return removed, nil
+}
+
+func (s *Store) prune(bucket []byte, before time.Time) (int, error) {
+	removed := 0
+	cutoff := timeKey(before)
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucket)
+
+		// Deleting while iterating a bbolt cursor skips keys, so collect first.
+		var keys [][]byte
+		c := b.Cursor()
+		for k, _ := c.First(); k != nil && bytes.Compare(k, cutoff) < 0; k, _ = c.Next() {
+			keys = append(keys, append([]byte(nil), k...))
+		}
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		removed = len(keys)
+		return nil
+	})
+
+	return removed, err
+}
+
+// StartPruner runs Prune at the given interval.
+func (s *Store) StartPruner(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			removed, err := s.Prune(time.Now())
+			if err != nil {
+				log.Printf("Error pruning archive: %v", err)
+				continue
+			}
+			if removed > 0 {
+				log.Printf("Pruned %d entries from archive", removed)
+			}
+		}
+	}
+}
+
+// timeKey encodes t so that byte order matches time order.
+func timeKey(t time.Time) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	return key
+}
+
+func keyTime(key []byte) time.Time {
+	return time.Unix(0, int64(binary.BigEndian.Uint64(key)))
+}
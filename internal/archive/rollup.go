@@ -0,0 +1,300 @@
+package archive
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+	"wsrepeater/internal/weather"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Tier is a level of downsampled aggregates.
+type Tier int
+
+const (
+	FiveMinute Tier = iota
+	Hourly
+	Daily
+)
+
+var tiers = []Tier{FiveMinute, Hourly, Daily}
+
+func (t Tier) String() string {
+	switch t {
+	case FiveMinute:
+		return "5m"
+	case Hourly:
+		return "1h"
+	default:
+		return "1d"
+	}
+}
+
+func (t Tier) bucket() []byte {
+	return []byte("rollup_" + t.String())
+}
+
+func (t Tier) watermarkKey() []byte {
+	return []byte("watermark_" + t.String())
+}
+
+// start returns the start of the interval containing tm. Daily intervals
+// follow the station's local midnight.
+func (t Tier) start(tm time.Time, loc *time.Location) time.Time {
+	switch t {
+	case FiveMinute:
+		return tm.Truncate(5 * time.Minute)
+	case Hourly:
+		return tm.Truncate(time.Hour)
+	default:
+		tm = tm.In(loc)
+		return time.Date(tm.Year(), tm.Month(), tm.Day(), 0, 0, 0, 0, loc)
+	}
+}
+
+// next returns the start of the interval following the one starting at start.
+func (t Tier) next(start time.Time, loc *time.Location) time.Time {
+	switch t {
+	case FiveMinute:
+		return start.Add(5 * time.Minute)
+	case Hourly:
+		return start.Add(time.Hour)
+	default:
+		start = start.In(loc)
+		return time.Date(start.Year(), start.Month(), start.Day()+1, 0, 0, 0, 0, loc)
+	}
+}
+
+// StartRollups runs Rollup at the given interval.
+func (s *Store) StartRollups(interval time.Duration) {
+	if err := s.Rollup(time.Now()); err != nil {
+		log.Printf("Error rolling up archive: %v", err)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.Rollup(time.Now()); err != nil {
+				log.Printf("Error rolling up archive: %v", err)
+			}
+		}
+	}
+}
+
+// Rollup computes every completed interval of every tier that has not been
+// computed yet. Five-minute aggregates are built from raw observations once
+// RollupDelay has passed since the end of their interval, hourly from
+// five-minute and daily from hourly ones, which DailyHook may add to.
+func (s *Store) Rollup(now time.Time) error {
+	for _, tier := range tiers {
+		if err := s.rollupTier(tier, now); err != nil {
+			return fmt.Errorf("error rolling up %s tier: %v", tier, err)
+		}
+	}
+	return nil
+}
+
+func (s *Store) rollupTier(tier Tier, now time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		meta := tx.Bucket(metaBucket)
+		dst := tx.Bucket(tier.bucket())
+
+		// A tier can only advance as far as its source is complete
+		limit := now.Add(-s.opts.RollupDelay)
+		var src *bolt.Bucket
+		if tier == FiveMinute {
+			src = tx.Bucket(observationsBucket)
+		} else {
+			source := tier - 1
+			src = tx.Bucket(source.bucket())
+			w := meta.Get(source.watermarkKey())
+			if w == nil {
+				return nil
+			}
+			limit = keyTime(w)
+		}
+
+		var next time.Time
+		if w := meta.Get(tier.watermarkKey()); w != nil {
+			next = keyTime(w)
+		} else {
+			k, _ := src.Cursor().First()
+			if k == nil {
+				return nil
+			}
+			next = tier.start(keyTime(k), s.loc)
+		}
+
+		for {
+			end := tier.next(next, s.loc)
+			if end.After(limit) {
+				break
+			}
+
+			// Rain is counted from the observation preceding the interval
+			var prev *weather.Observation
+			if tier == FiveMinute {
+				var err error
+				if prev, err = observationBefore(src, next); err != nil {
+					return err
+				}
+			}
+
+			agg := NewAggregate(next)
+			var finer []*Aggregate
+			c := src.Cursor()
+			endKey := timeKey(end)
+			for k, v := c.Seek(timeKey(next)); k != nil && bytes.Compare(k, endKey) < 0; k, v = c.Next() {
+				if tier == FiveMinute {
+					obs := &weather.Observation{}
+					if err := json.Unmarshal(v, obs); err != nil {
+						return fmt.Errorf("error decoding %x: %v", k, err)
+					}
+					agg.Add(obs)
+					agg.AddRain(prev, obs)
+					prev = obs
+					continue
+				}
+
+				f := &Aggregate{}
+				if err := json.Unmarshal(v, f); err != nil {
+					return fmt.Errorf("error decoding %x: %v", k, err)
+				}
+				agg.Merge(f)
+				finer = append(finer, f)
+			}
+			if tier == Daily && agg.Count > 0 && s.opts.DailyHook != nil {
+				s.opts.DailyHook(agg, finer)
+			}
+
+			if agg.Count > 0 {
+				value, err := json.Marshal(agg)
+				if err != nil {
+					return err
+				}
+				if err := dst.Put(timeKey(next), value); err != nil {
+					return err
+				}
+			}
+			next = end
+		}
+
+		return meta.Put(tier.watermarkKey(), timeKey(next))
+	})
+}
+
+// Rollups returns the stored aggregates of tier with from <= start < to.
+func (s *Store) Rollups(tier Tier, from, to time.Time) ([]*Aggregate, error) {
+	var aggregates []*Aggregate
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(tier.bucket()).Cursor()
+		end := timeKey(to)
+		for k, v := c.Seek(timeKey(from)); k != nil && bytes.Compare(k, end) < 0; k, v = c.Next() {
+			agg := &Aggregate{}
+			if err := json.Unmarshal(v, agg); err != nil {
+				return fmt.Errorf("error decoding aggregate %x: %v", k, err)
+			}
+			aggregates = append(aggregates, agg)
+		}
+		return nil
+	})
+
+	return aggregates, err
+}
+
+// Summaries returns aggregates of tier covering [from, to). Intervals the
+// rollup job has not produced yet, such as the current one, are aggregated on
+// the fly from raw observations.
+func (s *Store) Summaries(tier Tier, from, to time.Time) ([]*Aggregate, error) {
+	var watermark time.Time
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if w := tx.Bucket(metaBucket).Get(tier.watermarkKey()); w != nil {
+			watermark = keyTime(w)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var aggregates []*Aggregate
+	if watermark.After(from) {
+		stored, err := s.Rollups(tier, from, minTime(to, watermark))
+		if err != nil {
+			return nil, err
+		}
+		aggregates = stored
+	}
+
+	rest := from
+	if watermark.After(rest) {
+		rest = watermark
+	}
+	if !rest.Before(to) {
+		return aggregates, nil
+	}
+
+	observations, err := s.Range(rest, to)
+	if err != nil {
+		return nil, err
+	}
+	var prev *weather.Observation
+	err = s.db.View(func(tx *bolt.Tx) error {
+		prev, err = observationBefore(tx.Bucket(observationsBucket), rest)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var current *Aggregate
+	for _, obs := range observations {
+		start := tier.start(obs.Time, s.loc)
+		if current == nil || !start.Equal(current.Start) {
+			current = NewAggregate(start)
+			aggregates = append(aggregates, current)
+		}
+		current.Add(obs)
+		current.AddRain(prev, obs)
+		prev = obs
+	}
+
+	return aggregates, nil
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+// FinestTier returns the finest tier whose aggregates, given their
+// retention, still reach back to from.
+func (s *Store) FinestTier(from, now time.Time) Tier {
+	for _, tier := range tiers {
+		retention := s.retention(tier)
+		if retention <= 0 || !from.Before(now.Add(-retention)) {
+			return tier
+		}
+	}
+	return Daily
+}
+
+func (s *Store) retention(tier Tier) time.Duration {
+	switch tier {
+	case FiveMinute:
+		return s.opts.FiveMinuteRetention
+	case Hourly:
+		return s.opts.HourlyRetention
+	default:
+		return s.opts.DailyRetention
+	}
+}
@@ -0,0 +1,199 @@
+package archive
+
+import (
+	"testing"
+	"time"
+	"wsrepeater/internal/weather"
+)
+
+func openTest(t *testing.T, opts Options) *Store {
+	t.Helper()
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+	s, err := Open(t.TempDir(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func putTemp(t *testing.T, s *Store, at time.Time, tempF float64) {
+	t.Helper()
+	if err := s.Put(&weather.Observation{Time: at, TempF: &tempF}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRollupDailyHook(t *testing.T) {
+	var hours int
+	s := openTest(t, Options{DailyHook: func(day *Aggregate, finer []*Aggregate) {
+		hours = len(finer)
+		day.Set("hours", float64(len(finer)))
+	}})
+
+	day := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+	for h := 0; h < 24; h += 6 {
+		putTemp(t, s, day.Add(time.Duration(h)*time.Hour+time.Minute), 50)
+	}
+	if err := s.Rollup(day.AddDate(0, 0, 1).Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	daily, err := s.Rollups(Daily, day, day.AddDate(0, 0, 1))
+	if err != nil || len(daily) != 1 {
+		t.Fatalf("Rollups = %d aggregates, %v", len(daily), err)
+	}
+	if hours != 4 {
+		t.Errorf("hook was given %d hourly aggregates, want 4", hours)
+	}
+	if stat := daily[0].Stat("hours"); stat == nil || stat.Last != 4 {
+		t.Errorf("stored hook value = %+v, want 4", stat)
+	}
+}
+
+func TestRollupAlignment(t *testing.T) {
+	loc, err := time.LoadLocation("America/Moncton")
+	if err != nil {
+		t.Skip(err)
+	}
+	s := openTest(t, Options{Location: loc})
+
+	// Every 10 minutes over two local days
+	start := time.Date(2026, time.March, 1, 0, 3, 0, 0, loc)
+	end := start.AddDate(0, 0, 2)
+	for at := start; at.Before(end); at = at.Add(10 * time.Minute) {
+		putTemp(t, s, at, float64(at.In(loc).Hour()))
+	}
+	if err := s.Rollup(end.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		tier     Tier
+		from     time.Time
+		want     int
+		interval time.Duration
+		count    int
+	}{
+		{FiveMinute, start, 2 * 24 * 6, 5 * time.Minute, 1},
+		{Hourly, start, 2 * 24, time.Hour, 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tier.String(), func(t *testing.T) {
+			aggregates, err := s.Rollups(tt.tier, tt.from.Add(-time.Hour), end)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(aggregates) != tt.want {
+				t.Fatalf("%d aggregates, want %d", len(aggregates), tt.want)
+			}
+			for _, agg := range aggregates {
+				if !agg.Start.Equal(agg.Start.Truncate(tt.interval)) || agg.Count != tt.count {
+					t.Fatalf("aggregate at %v holds %d observations, want %d aligned to %v", agg.Start, agg.Count, tt.count, tt.interval)
+				}
+			}
+		})
+	}
+
+	daily, err := s.Rollups(Daily, start.Add(-time.Hour), end)
+	if err != nil || len(daily) != 2 {
+		t.Fatalf("Rollups = %d daily aggregates, %v, want 2", len(daily), err)
+	}
+	for i, agg := range daily {
+		midnight := time.Date(2026, time.March, 1+i, 0, 0, 0, 0, loc)
+		if !agg.Start.Equal(midnight) || agg.Count != 144 {
+			t.Errorf("day %d starts at %v with %d observations, want %v with 144", i+1, agg.Start.In(loc), agg.Count, midnight)
+		}
+		if temp := agg.Stat("tempf"); temp == nil || temp.Min != 0 || temp.Max != 23 || temp.Avg() != 11.5 {
+			t.Errorf("day %d temperature = %+v, want 0 to 23, mean 11.5", i+1, temp)
+		}
+	}
+}
+
+func TestRollupLateObservation(t *testing.T) {
+	s := openTest(t, Options{RollupDelay: 2 * time.Minute})
+
+	interval := time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)
+	putTemp(t, s, interval.Add(time.Minute), 50)
+
+	// A minute after the interval ended, the delayed report isn't in yet
+	if err := s.Rollup(interval.Add(6 * time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	putTemp(t, s, interval.Add(4*time.Minute), 60)
+	if err := s.Rollup(interval.Add(8 * time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	aggregates, err := s.Rollups(FiveMinute, interval, interval.Add(5*time.Minute))
+	if err != nil || len(aggregates) != 1 {
+		t.Fatalf("Rollups = %d aggregates, %v, want 1", len(aggregates), err)
+	}
+	if temp := aggregates[0].Stat("tempf"); aggregates[0].Count != 2 || temp.Max != 60 {
+		t.Errorf("aggregate holds %d observations up to %v, want the late one merged", aggregates[0].Count, temp.Max)
+	}
+}
+
+func TestRollupRain(t *testing.T) {
+	s := openTest(t, Options{})
+
+	// The console's daily counter resets at 00:00 between two intervals
+	start := time.Date(2026, time.March, 1, 23, 50, 0, 0, time.UTC)
+	for i, daily := range []float64{0.10, 0.20, 0.02, 0.05} {
+		daily := daily
+		obs := &weather.Observation{Time: start.Add(time.Duration(i) * 5 * time.Minute), DailyRainIn: &daily}
+		if err := s.Put(obs); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Rollup(start.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	aggregates, err := s.Rollups(FiveMinute, start, start.Add(20*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []float64{0, 0.10, 0.02, 0.03}
+	if len(aggregates) != len(want) {
+		t.Fatalf("%d aggregates, want %d", len(aggregates), len(want))
+	}
+	for i, agg := range aggregates {
+		got := 0.0
+		if stat := agg.Stat(RainKey); stat != nil {
+			got = stat.Sum
+		}
+		if diff := got - want[i]; diff > 1e-9 || diff < -1e-9 {
+			t.Errorf("rain at %v = %v, want %v", agg.Start.Format("15:04"), got, want[i])
+		}
+	}
+}
+
+func TestSummariesBeyondWatermark(t *testing.T) {
+	s := openTest(t, Options{})
+
+	start := time.Date(2026, time.March, 1, 10, 0, 0, 0, time.UTC)
+	for minutes := 0; minutes < 150; minutes += 10 {
+		putTemp(t, s, start.Add(time.Duration(minutes)*time.Minute), 50)
+	}
+	// Rolled up to 11:00; the rest is aggregated on the fly
+	if err := s.Rollup(start.Add(time.Hour + 6*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	aggregates, err := s.Summaries(Hourly, start, start.Add(3*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := []int{6, 6, 3}
+	if len(aggregates) != len(counts) {
+		t.Fatalf("%d hourly summaries, want %d", len(aggregates), len(counts))
+	}
+	for i, agg := range aggregates {
+		if want := start.Add(time.Duration(i) * time.Hour); !agg.Start.Equal(want) || agg.Count != counts[i] {
+			t.Errorf("summary %d starts at %v with %d observations, want %v with %d", i, agg.Start, agg.Count, want, counts[i])
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package calibration
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"wsrepeater/internal/weather"
+)
+
+// envPrefix starts the environment variables holding pipelines, e.g.
+// CALIBRATE_TEMPF=offset:-0.4 or CALIBRATE_BAROMRELIN=offset:0.03.
+const envPrefix = "CALIBRATE_"
+
+// defaults reproduce the corrections wsrepeater has always applied to the
+// solar sensor. Set CALIBRATE_UV=none to upload raw values instead.
+var defaults = map[string]string{
+	"uv":             "sma:5|multiply:0.94|round:0",
+	"solarradiation": "sma:5|multiply:0.94",
+}
+
+// Pipeline is the chain of stages applied to one Ecowitt parameter. Stages
+// are written name:arguments and separated by "|", and run in order:
+//
+//	offset:x         add x
+//	multiply:x       multiply by x (also scale:x)
+//	poly:c0,c1,c2... c0 + c1·v + c2·v² + ...
+//	clamp:min,max    limit to [min, max]; either bound may be left empty
+//	round:n          round to n decimals (0 if omitted)
+//	sma:n            moving average over the last n samples
+//	avg:d            average over the last duration d, e.g. avg:10m
+//	ema:a            exponential moving average with weight a, or with a
+//	                 time constant if a is a duration, e.g. ema:5m
+//	median:n         median of the last n samples
+//	hampel:n,k,d     replace samples more than k (default 3) deviations
+//	                 from the median of the last n samples, counting a
+//	                 deviation of at least d (by default about the
+//	                 parameter's resolution)
+type Pipeline struct {
+	Key    string
+	Spec   string
+	stages []Stage
+}
+
+// Parse builds the pipeline spec for the parameter key.
+func Parse(key, spec string) (*Pipeline, error) {
+	if !weather.IsKey(key) {
+		return nil, fmt.Errorf("unknown parameter %q", key)
+	}
+
+	p := &Pipeline{Key: key, Spec: spec}
+	for _, s := range strings.Split(spec, "|") {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		stage, err := parseStage(key, s)
+		if err != nil {
+			return nil, err
+		}
+		p.stages = append(p.stages, stage)
+	}
+	return p, nil
+}
+
+// Apply runs v, measured at t, through every stage.
+func (p *Pipeline) Apply(v float64, t time.Time) float64 {
+	for _, stage := range p.stages {
+		v = stage.Apply(v, t)
+	}
+	return v
+}
+
+// Calibrator corrects observations with one pipeline per parameter.
+type Calibrator struct {
+	pipelines []*Pipeline
+}
+
+// Load builds the pipelines from the CALIBRATE_<KEY> environment variables,
+// on top of the defaults. A value of "none" removes a default pipeline.
+func Load() (*Calibrator, error) {
+	specs := make(map[string]string)
+	for key, spec := range defaults {
+		specs[key] = spec
+	}
+	for _, env := range os.Environ() {
+		name, spec, _ := strings.Cut(env, "=")
+		if !strings.HasPrefix(name, envPrefix) {
+			continue
+		}
+		specs[strings.ToLower(strings.TrimPrefix(name, envPrefix))] = spec
+	}
+
+	keys := make([]string, 0, len(specs))
+	for key := range specs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	c := &Calibrator{}
+	for _, key := range keys {
+		spec := strings.TrimSpace(specs[key])
+		if spec == "" || strings.EqualFold(spec, "none") {
+			continue
+		}
+		p, err := Parse(key, spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s%s: %v", envPrefix, strings.ToUpper(key), err)
+		}
+		c.pipelines = append(c.pipelines, p)
+	}
+	return c, nil
+}
+
+// Pipelines returns the configured pipelines, ordered by parameter.
+func (c *Calibrator) Pipelines() []*Pipeline {
+	return c.pipelines
+}
+
+// Apply returns a copy of obs with every calibrated parameter corrected.
+// Parameters missing from obs are left out and don't advance any filter.
+func (c *Calibrator) Apply(obs *weather.Observation) *weather.Observation {
+	calibrated := obs.Clone()
+	for _, p := range c.pipelines {
+		if v, ok := obs.Get(p.Key); ok {
+			calibrated.Set(p.Key, p.Apply(v, obs.Time))
+		}
+	}
+	return calibrated
+}
@@ -0,0 +1,145 @@
+package calibration
+
+import (
+	"math"
+	"testing"
+	"time"
+	"wsrepeater/internal/weather"
+)
+
+// legacySmooth is the five-sample moving average wsrepeater used to apply
+// to UV and solar radiation before calibration pipelines existed.
+func legacySmooth(values *[]float64, v float64) float64 {
+	*values = append(*values, v)
+	if len(*values) > 5 {
+		*values = (*values)[1:]
+	}
+	sum := 0.0
+	for _, x := range *values {
+		sum += x
+	}
+	return sum / float64(len(*values))
+}
+
+func TestDefaultsMatchLegacyCorrection(t *testing.T) {
+	uv, err := Parse("uv", defaults["uv"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	solar, err := Parse("solarradiation", defaults["solarradiation"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var uvValues, solarValues []float64
+	readings := []float64{0, 1, 3, 4, 4, 6, 7, 2, 2, 5, 9, 11, 3}
+	for i, v := range readings {
+		at := time.Date(2026, time.June, 1, 12, i, 0, 0, time.UTC)
+
+		if got, want := uv.Apply(v, at), math.Round(legacySmooth(&uvValues, v)*0.94); got != want {
+			t.Errorf("reading %d: UV = %v, want %v", i+1, got, want)
+		}
+		irradiance := v * 100
+		if got, want := solar.Apply(irradiance, at), legacySmooth(&solarValues, irradiance)*0.94; math.Abs(got-want) > 1e-9 {
+			t.Errorf("reading %d: solar radiation = %v, want %v", i+1, got, want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec   string
+		inputs []float64
+		want   float64
+	}{
+		{"offset:-0.5", []float64{70}, 69.5},
+		{"multiply:1.1", []float64{10}, 11},
+		{"scale:2", []float64{10}, 20},
+		{"poly:1,2,0.5", []float64{2}, 7},
+		{"clamp:0,100", []float64{104}, 100},
+		{"clamp:,100", []float64{-5}, -5},
+		{"round:1", []float64{1.26}, 1.3},
+		{"round", []float64{1.6}, 2},
+		{"sma:3", []float64{1, 2, 3, 10}, 5},
+		{"median:3", []float64{1, 50, 2}, 2},
+		{"ema:0.5", []float64{10, 20}, 15},
+		{"hampel:5", []float64{20, 20, 20, 20, 95}, 20},
+		{"hampel:5,3,30", []float64{20, 20, 20, 20, 95}, 95},
+		{"hampel:5,3,30", []float64{20, 20, 20, 20, 120}, 20},
+		{" offset:1 | multiply:2 || round:0 ", []float64{1.4}, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			p, err := Parse("tempf", tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got float64
+			for i, v := range tt.inputs {
+				got = p.Apply(v, time.Date(2026, time.June, 1, 12, i, 0, 0, time.UTC))
+			}
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Apply(%v) = %v, want %v", tt.inputs, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		key, spec string
+	}{
+		{"nosuchkey", "offset:1"},
+		{"tempf", "offset:warm"},
+		{"tempf", "offset:NaN"},
+		{"tempf", "unknown:1"},
+		{"tempf", "clamp:0"},
+		{"tempf", "round:-1"},
+		{"tempf", "sma:0"},
+		{"tempf", "avg:-5m"},
+		{"tempf", "ema:1.5"},
+		{"tempf", "hampel:5,0"},
+		{"tempf", "hampel:5,3,-1"},
+	}
+	for _, tt := range tests {
+		if _, err := Parse(tt.key, tt.spec); err == nil {
+			t.Errorf("Parse(%q, %q) succeeded", tt.key, tt.spec)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Setenv("CALIBRATE_UV", "none")
+	t.Setenv("CALIBRATE_TEMPF", "offset:-1")
+
+	c, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, p := range c.Pipelines() {
+		keys = append(keys, p.Key)
+	}
+	if len(keys) != 2 || keys[0] != "solarradiation" || keys[1] != "tempf" {
+		t.Errorf("pipelines = %v, want the solar default and tempf", keys)
+	}
+
+	temp, uv := 70.0, 5.0
+	obs := &weather.Observation{Time: time.Now(), TempF: &temp, UV: &uv}
+	calibrated := c.Apply(obs)
+	if *calibrated.TempF != 69 || *calibrated.UV != 5 {
+		t.Errorf("calibrated tempf, uv = %v, %v, want 69, 5", *calibrated.TempF, *calibrated.UV)
+	}
+	if calibrated.SolarRadiation != nil {
+		t.Error("calibration added a missing solar radiation")
+	}
+	if *obs.TempF != 70 {
+		t.Error("calibration modified the original observation")
+	}
+
+	t.Setenv("CALIBRATE_TEMPF", "offset:warm")
+	if _, err := Load(); err == nil {
+		t.Error("Load accepted an invalid pipeline")
+	}
+}
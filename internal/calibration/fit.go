@@ -0,0 +1,156 @@
+package calibration
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fit is a least-squares polynomial mapping station readings to reference
+// readings.
+type Fit struct {
+	// Coefficients in increasing order, as taken by the poly stage
+	Coefficients []float64
+	R2           float64
+	RMSE         float64
+	// Bias is the mean residual, MaxResidual the largest in magnitude
+	Bias        float64
+	MaxResidual float64
+	// Residuals are reference minus corrected station readings, per pair
+	Residuals []float64
+}
+
+// FitPolynomial fits reference ≈ c0 + c1·station + ... + cn·stationⁿ for
+// degree n.
+func FitPolynomial(station, reference []float64, degree int) (*Fit, error) {
+	if len(station) != len(reference) {
+		return nil, fmt.Errorf("%d station readings for %d reference readings", len(station), len(reference))
+	}
+	if degree < 1 {
+		return nil, fmt.Errorf("degree must be at least 1")
+	}
+	if len(station) <= degree {
+		return nil, fmt.Errorf("need more than %d readings for a degree %d fit, have %d", degree, degree, len(station))
+	}
+
+	// Normal equations: (XᵀX)c = Xᵀy, with X the Vandermonde matrix
+	n := degree + 1
+	a := make([][]float64, n)
+	for i := range a {
+		a[i] = make([]float64, n+1)
+	}
+	for k, x := range station {
+		powers := make([]float64, 2*n)
+		powers[0] = 1
+		for i := 1; i < len(powers); i++ {
+			powers[i] = powers[i-1] * x
+		}
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				a[i][j] += powers[i+j]
+			}
+			a[i][n] += powers[i] * reference[k]
+		}
+	}
+
+	coefficients, err := solve(a)
+	if err != nil {
+		return nil, err
+	}
+
+	fit := &Fit{Coefficients: coefficients, Residuals: make([]float64, len(station))}
+	p := polynomial(coefficients)
+
+	mean := 0.0
+	for _, y := range reference {
+		mean += y
+	}
+	mean /= float64(len(reference))
+
+	var ssRes, ssTot float64
+	for i, x := range station {
+		r := reference[i] - p.Apply(x, time.Time{})
+		fit.Residuals[i] = r
+		fit.Bias += r
+		if math.Abs(r) > math.Abs(fit.MaxResidual) {
+			fit.MaxResidual = r
+		}
+		ssRes += r * r
+		ssTot += (reference[i] - mean) * (reference[i] - mean)
+	}
+	fit.Bias /= float64(len(station))
+	fit.RMSE = math.Sqrt(ssRes / float64(len(station)))
+	if ssTot > 0 {
+		fit.R2 = 1 - ssRes/ssTot
+	}
+
+	return fit, nil
+}
+
+// solve solves the augmented system a by Gaussian elimination with partial
+// pivoting.
+func solve(a [][]float64) ([]float64, error) {
+	n := len(a)
+	for col := 0; col < n; col++ {
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			return nil, fmt.Errorf("readings don't vary enough to fit a degree %d polynomial", n-1)
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+
+		for row := col + 1; row < n; row++ {
+			f := a[row][col] / a[col][col]
+			for k := col; k <= n; k++ {
+				a[row][k] -= f * a[col][k]
+			}
+		}
+	}
+
+	x := make([]float64, n)
+	for row := n - 1; row >= 0; row-- {
+		sum := a[row][n]
+		for k := row + 1; k < n; k++ {
+			sum -= a[row][k] * x[k]
+		}
+		x[row] = sum / a[row][row]
+	}
+	return x, nil
+}
+
+// Stage returns the fit as a pipeline stage, e.g. "poly:0.12,0.97".
+func (f *Fit) Stage() string {
+	coefficients := make([]string, len(f.Coefficients))
+	for i, c := range f.Coefficients {
+		coefficients[i] = strconv.FormatFloat(c, 'g', 6, 64)
+	}
+	return "poly:" + strings.Join(coefficients, ",")
+}
+
+// AppendStage adds stage to the pipeline spec, ahead of any trailing round
+// and clamp stages so that the output keeps its final formatting.
+func AppendStage(spec, stage string) string {
+	if strings.TrimSpace(spec) == "" || strings.EqualFold(strings.TrimSpace(spec), "none") {
+		return stage
+	}
+
+	stages := strings.Split(spec, "|")
+	i := len(stages)
+	for i > 0 {
+		name, _, _ := strings.Cut(strings.TrimSpace(stages[i-1]), ":")
+		if name != "round" && name != "clamp" {
+			break
+		}
+		i--
+	}
+
+	result := append([]string{}, stages[:i]...)
+	result = append(result, stage)
+	return strings.Join(append(result, stages[i:]...), "|")
+}
@@ -0,0 +1,186 @@
+package calibration
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"wsrepeater/internal/filter"
+)
+
+// Stage is one step of a calibration pipeline. Filter stages keep state
+// between calls, so each pipeline owns its own stages.
+type Stage interface {
+	Apply(v float64, t time.Time) float64
+}
+
+type offset float64
+
+func (s offset) Apply(v float64, _ time.Time) float64 { return v + float64(s) }
+
+type multiplier float64
+
+func (s multiplier) Apply(v float64, _ time.Time) float64 { return v * float64(s) }
+
+// polynomial holds coefficients in increasing order: c0 + c1·v + c2·v² ...
+type polynomial []float64
+
+func (s polynomial) Apply(v float64, _ time.Time) float64 {
+	result := 0.0
+	for i := len(s) - 1; i >= 0; i-- {
+		result = result*v + s[i]
+	}
+	return result
+}
+
+type clamp struct {
+	min, max float64
+}
+
+func (s clamp) Apply(v float64, _ time.Time) float64 {
+	return math.Max(s.min, math.Min(s.max, v))
+}
+
+type round int
+
+func (s round) Apply(v float64, _ time.Time) float64 {
+	scale := math.Pow(10, float64(s))
+	return math.Round(v*scale) / scale
+}
+
+// hampelFloors are the least deviations, in the gateway's units, a Hampel
+// stage assumes for a parameter: about the resolution it is reported with.
+// Other parameters use defaultHampelFloor.
+var hampelFloors = map[string]float64{
+	"tempf":          0.1,
+	"tempinf":        0.1,
+	"humidity":       1,
+	"humidityin":     1,
+	"baromrelin":     0.01,
+	"baromabsin":     0.01,
+	"winddir":        1,
+	"windspeedmph":   0.2,
+	"windgustmph":    0.2,
+	"solarradiation": 0.1,
+	"uv":             1,
+}
+
+const defaultHampelFloor = 0.01
+
+// parseStage parses one "name:arguments" stage of the pipeline of key.
+func parseStage(key, spec string) (Stage, error) {
+	name, arg, _ := strings.Cut(spec, ":")
+	name = strings.ToLower(strings.TrimSpace(name))
+	arg = strings.TrimSpace(arg)
+
+	switch name {
+	case "offset":
+		v, err := parseNumber(arg)
+		return offset(v), err
+	case "multiply", "scale":
+		v, err := parseNumber(arg)
+		return multiplier(v), err
+	case "poly", "polynomial":
+		var coefficients polynomial
+		for _, c := range strings.Split(arg, ",") {
+			v, err := parseNumber(c)
+			if err != nil {
+				return nil, err
+			}
+			coefficients = append(coefficients, v)
+		}
+		return coefficients, nil
+	case "clamp":
+		low, high, ok := strings.Cut(arg, ",")
+		if !ok {
+			return nil, fmt.Errorf("clamp needs min,max")
+		}
+		c := clamp{min: math.Inf(-1), max: math.Inf(1)}
+		var err error
+		if strings.TrimSpace(low) != "" {
+			if c.min, err = parseNumber(low); err != nil {
+				return nil, err
+			}
+		}
+		if strings.TrimSpace(high) != "" {
+			if c.max, err = parseNumber(high); err != nil {
+				return nil, err
+			}
+		}
+		return c, nil
+	case "round":
+		decimals := 0
+		if arg != "" {
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid number of decimals %q", arg)
+			}
+			decimals = n
+		}
+		return round(decimals), nil
+	case "sma":
+		n, err := parseCount(arg)
+		return filter.NewMovingAverage(n), err
+	case "avg":
+		window, err := time.ParseDuration(arg)
+		if err != nil || window <= 0 {
+			return nil, fmt.Errorf("invalid averaging window %q", arg)
+		}
+		return filter.NewTimeAverage(window), nil
+	case "ema":
+		// Either a time constant such as 5m, or a fixed weight such as 0.2
+		if tau, err := time.ParseDuration(arg); err == nil && tau > 0 {
+			return filter.NewTimeEMA(tau), nil
+		}
+		alpha, err := parseNumber(arg)
+		if err != nil || alpha <= 0 || alpha > 1 {
+			return nil, fmt.Errorf("invalid EMA weight or time constant %q", arg)
+		}
+		return filter.NewEMA(alpha), nil
+	case "median":
+		n, err := parseCount(arg)
+		return filter.NewMedian(n), err
+	case "hampel":
+		size, rest, _ := strings.Cut(arg, ",")
+		threshold, minimum, _ := strings.Cut(rest, ",")
+		n, err := parseCount(size)
+		if err != nil {
+			return nil, err
+		}
+		k := 3.0
+		if strings.TrimSpace(threshold) != "" {
+			if k, err = parseNumber(threshold); err != nil || k <= 0 {
+				return nil, fmt.Errorf("invalid Hampel threshold %q", threshold)
+			}
+		}
+		floor, ok := hampelFloors[key]
+		if !ok {
+			floor = defaultHampelFloor
+		}
+		if strings.TrimSpace(minimum) != "" {
+			if floor, err = parseNumber(minimum); err != nil || floor <= 0 {
+				return nil, fmt.Errorf("invalid Hampel minimum deviation %q", minimum)
+			}
+		}
+		return filter.NewHampel(n, k, floor), nil
+	default:
+		return nil, fmt.Errorf("unknown stage %q", name)
+	}
+}
+
+func parseCount(s string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid sample count %q", s)
+	}
+	return n, nil
+}
+
+func parseNumber(s string) (float64, error) {
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	return v, nil
+}
@@ -3,26 +3,30 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 func LoadConfig() {
-	// Load the .env file if environment variables are not set
-	err := godotenv.Load()
-	if err != nil && !fileExists(".env") {
-		log.Fatalf("Error loading .env file: %v", err)
-	}
+	LoadEnv()
 
-	// List of required environment variables
+	// List of required environment variables. The upload credentials of
+	// each sink, Wunderground's included, are checked when it is enabled.
 	requiredEnvVars := []string{
-		"WUNDERGROUND_ID",
-		"WUNDERGROUND_PASS",
 		"STATION_SOFTWARE",
-		"WUNDERGROUND_API_KEY",
 		"ASTRO_API_KEY",
 	}
 
+	// History served from the local archive needs the station's location
+	// instead of a Weather Underground station ID and API key
+	if LocalHistory() {
+		requiredEnvVars = append(requiredEnvVars, "STATION_LATITUDE", "STATION_LONGITUDE")
+	} else {
+		requiredEnvVars = append(requiredEnvVars, "WUNDERGROUND_ID", "WUNDERGROUND_API_KEY")
+	}
+
 	// Check if all required environment variables are set
 	for _, envVar := range requiredEnvVars {
 		if os.Getenv(envVar) == "" {
@@ -31,6 +35,47 @@ func LoadConfig() {
 	}
 }
 
+// LoadEnv loads the .env file, without checking that the server's
+// required variables are set.
+func LoadEnv() {
+	// Load the .env file if environment variables are not set
+	err := godotenv.Load()
+	if err != nil && !fileExists(".env") {
+		log.Fatalf("Error loading .env file: %v", err)
+	}
+}
+
+// LocalHistory reports whether /wutoday and /weekly are built from the local
+// archive (HISTORY_SOURCE=local) rather than the Weather Underground API.
+func LocalHistory() bool {
+	return GetString("HISTORY_SOURCE", "wu") == "local"
+}
+
+// StationLocation returns the station's latitude and longitude in degrees.
+func StationLocation() (float64, float64) {
+	return GetFloat("STATION_LATITUDE", 0), GetFloat("STATION_LONGITUDE", 0)
+}
+
+// HasStationLocation reports whether STATION_LATITUDE and STATION_LONGITUDE
+// are both set.
+func HasStationLocation() bool {
+	return os.Getenv("STATION_LATITUDE") != "" && os.Getenv("STATION_LONGITUDE") != ""
+}
+
+// StationTimezone returns the station's time zone, which decides where the
+// day boundaries of local history fall. It defaults to the server's zone.
+func StationTimezone() *time.Location {
+	name := os.Getenv("STATION_TIMEZONE")
+	if name == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		log.Fatalf("Environment variable STATION_TIMEZONE is not a valid time zone: %v", err)
+	}
+	return loc
+}
+
 func fileExists(filename string) bool {
 	info, err := os.Stat(filename)
 	if os.IsNotExist(err) {
@@ -38,3 +83,74 @@ func fileExists(filename string) bool {
 	}
 	return !info.IsDir()
 }
+
+// GetString returns the value of the environment variable key, or def if it
+// is not set.
+func GetString(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// GetInt returns the environment variable key parsed as an integer, or def if
+// it is not set.
+func GetInt(key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Fatalf("Environment variable %s is not an integer: %v", key, err)
+	}
+	return n
+}
+
+// GetFloat returns the environment variable key parsed as a float, or def if
+// it is not set.
+func GetFloat(key string, def float64) float64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		log.Fatalf("Environment variable %s is not a number: %v", key, err)
+	}
+	return f
+}
+
+// GetBool returns the environment variable key parsed as a boolean, or def if
+// it is not set.
+func GetBool(key string, def bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		log.Fatalf("Environment variable %s is not a boolean: %v", key, err)
+	}
+	return b
+}
+
+// GetDuration returns the environment variable key parsed as a duration
+// (e.g. "90s", "5m"), or def if it is not set.
+func GetDuration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Fatalf("Environment variable %s is not a duration: %v", key, err)
+	}
+	return d
+}
+
+// GetDays returns the environment variable key, a number of days, as a
+// duration, or def days if it is not set. Zero means "forever".
+func GetDays(key string, def int) time.Duration {
+	return time.Duration(GetInt(key, def)) * 24 * time.Hour
+}
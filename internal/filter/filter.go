@@ -0,0 +1,126 @@
+// Package filter smooths noisy sensor readings. Filters are stateful and
+// safe for concurrent use; give each sensor its own instance.
+package filter
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Filter takes a reading v measured at t and returns the filtered value.
+type Filter interface {
+	Apply(v float64, t time.Time) float64
+}
+
+type sample struct {
+	v float64
+	t time.Time
+}
+
+// MovingAverage is the simple average of the last size readings.
+type MovingAverage struct {
+	size   int
+	values []float64
+	mutex  sync.Mutex
+}
+
+func NewMovingAverage(size int) *MovingAverage {
+	return &MovingAverage{size: size}
+}
+
+func (f *MovingAverage) Apply(v float64, _ time.Time) float64 {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.values = append(f.values, v)
+	if len(f.values) > f.size {
+		f.values = f.values[1:]
+	}
+	return mean(f.values)
+}
+
+// TimeAverage averages the readings of the last window, however many there
+// are. Unlike MovingAverage it smooths over the same period whatever the
+// gateway's report interval.
+type TimeAverage struct {
+	window  time.Duration
+	samples []sample
+	mutex   sync.Mutex
+}
+
+func NewTimeAverage(window time.Duration) *TimeAverage {
+	return &TimeAverage{window: window}
+}
+
+func (f *TimeAverage) Apply(v float64, t time.Time) float64 {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.samples = append(f.samples, sample{v, t})
+	cutoff := t.Add(-f.window)
+	i := 0
+	for i < len(f.samples)-1 && !f.samples[i].t.After(cutoff) {
+		i++
+	}
+	f.samples = f.samples[i:]
+
+	sum := 0.0
+	for _, s := range f.samples {
+		sum += s.v
+	}
+	return sum / float64(len(f.samples))
+}
+
+// EMA is an exponential moving average. With a time constant, the weight of
+// each reading depends on the time elapsed since the previous one, so
+// irregular report intervals don't skew it; otherwise alpha is fixed.
+type EMA struct {
+	alpha  float64
+	tau    time.Duration
+	value  float64
+	last   time.Time
+	primed bool
+	mutex  sync.Mutex
+}
+
+// NewEMA returns an EMA giving weight alpha (0 < alpha <= 1) to each new
+// reading.
+func NewEMA(alpha float64) *EMA {
+	return &EMA{alpha: alpha}
+}
+
+// NewTimeEMA returns an EMA with time constant tau.
+func NewTimeEMA(tau time.Duration) *EMA {
+	return &EMA{tau: tau}
+}
+
+func (f *EMA) Apply(v float64, t time.Time) float64 {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if !f.primed {
+		f.value, f.last, f.primed = v, t, true
+		return v
+	}
+
+	alpha := f.alpha
+	if f.tau > 0 {
+		dt := t.Sub(f.last)
+		if dt < 0 {
+			dt = 0
+		}
+		alpha = 1 - math.Exp(-float64(dt)/float64(f.tau))
+	}
+	f.last = t
+	f.value += alpha * (v - f.value)
+	return f.value
+}
+
+func mean(values []float64) float64 {
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
@@ -0,0 +1,90 @@
+package filter
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// madScale turns the median absolute deviation into an estimate of the
+// standard deviation for normally distributed readings.
+const madScale = 1.4826
+
+// Median returns the median of the last size readings, which removes single
+// spikes without smearing them into their neighbours like an average does.
+type Median struct {
+	size   int
+	values []float64
+	mutex  sync.Mutex
+}
+
+func NewMedian(size int) *Median {
+	return &Median{size: size}
+}
+
+func (f *Median) Apply(v float64, _ time.Time) float64 {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.values = append(f.values, v)
+	if len(f.values) > f.size {
+		f.values = f.values[1:]
+	}
+	return median(f.values)
+}
+
+// Hampel rejects outliers: a reading further than k scaled median absolute
+// deviations from the median of the last size readings is replaced by that
+// median. Other readings pass through unchanged. The deviation is never
+// taken as less than floor, so that a window of identical readings, such as
+// a solar sensor at night, still rejects a spike.
+type Hampel struct {
+	size   int
+	k      float64
+	floor  float64
+	values []float64
+	mutex  sync.Mutex
+}
+
+func NewHampel(size int, k, floor float64) *Hampel {
+	return &Hampel{size: size, k: k, floor: floor}
+}
+
+func (f *Hampel) Apply(v float64, _ time.Time) float64 {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.values = append(f.values, v)
+	if len(f.values) > f.size {
+		f.values = f.values[1:]
+	}
+
+	// Too few readings to tell an outlier from a change
+	if len(f.values) < 3 {
+		return v
+	}
+
+	m := median(f.values)
+	deviations := make([]float64, len(f.values))
+	for i, x := range f.values {
+		deviations[i] = math.Abs(x - m)
+	}
+	scale := math.Max(madScale*median(deviations), f.floor)
+
+	if math.Abs(v-m) > f.k*scale {
+		return m
+	}
+	return v
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
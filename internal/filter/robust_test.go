@@ -0,0 +1,47 @@
+package filter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHampel(t *testing.T) {
+	tests := []struct {
+		name     string
+		size     int
+		floor    float64
+		readings []float64
+		want     float64
+	}{
+		{"warm-up", 7, 0.1, []float64{5, 900}, 900},
+		{"spike after a flat window", 7, 0.1, []float64{20, 20, 20, 20, 20, 20, 95}, 20},
+		{"sunlight after a dark night", 7, 0.1, []float64{0, 0, 0, 0, 0, 0, 900}, 0},
+		{"change within the floor", 7, 0.1, []float64{20, 20, 20, 20, 20, 20, 20.2}, 20.2},
+		{"spike in a noisy window", 7, 0.1, []float64{20, 21, 19, 20, 22, 18, 95}, 20},
+		{"change in a noisy window", 7, 0.1, []float64{20, 21, 19, 20, 22, 18, 23}, 23},
+		{"step once half the window has changed", 3, 0.1, []float64{0, 0, 0, 900, 900}, 900},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewHampel(tt.size, 3, tt.floor)
+			var got float64
+			for _, v := range tt.readings {
+				got = f.Apply(v, time.Time{})
+			}
+			if got != tt.want {
+				t.Errorf("Apply(%v) = %v, want %v", tt.readings, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMedian(t *testing.T) {
+	f := NewMedian(3)
+	want := []float64{1, 5.5, 2, 3, 3}
+	for i, v := range []float64{1, 10, 2, 3, 50} {
+		if got := f.Apply(v, time.Time{}); got != want[i] {
+			t.Errorf("reading %d: Apply = %v, want %v", i+1, got, want[i])
+		}
+	}
+}
@@ -0,0 +1,209 @@
+// Package forecast derives the pressure tendency and a Zambretti forecast
+// from the station's own barometer.
+package forecast
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	// TendencyPeriod is the interval over which the tendency is measured.
+	TendencyPeriod = 3 * time.Hour
+	// sampleWindow is averaged around each point of the curve to smooth out
+	// the sensor's resolution.
+	sampleWindow = 5 * time.Minute
+	// maxGap is how far the readings may be from the points of the curve.
+	maxGap = 20 * time.Minute
+	// steadyChange is the change in hPa below which a half of the curve
+	// counts as steady.
+	steadyChange = 0.1
+)
+
+// Reading is one pressure reading, in hPa.
+type Reading struct {
+	Time     time.Time
+	Pressure float64
+}
+
+// Tendency describes how pressure changed over the last three hours.
+type Tendency struct {
+	Time        time.Time `json:"time"`
+	Pressure    float64   `json:"pressure"`
+	Change      float64   `json:"change3h"`
+	Trend       string    `json:"trend"`
+	Description string    `json:"description"`
+	// WMOCode is the pressure characteristic of WMO code table 0200
+	WMOCode        int    `json:"wmoCode"`
+	WMODescription string `json:"wmoDescription"`
+}
+
+var wmoDescriptions = [9]string{
+	"Increasing, then decreasing; pressure the same or higher than 3 hours ago",
+	"Increasing, then steady; or increasing, then increasing more slowly",
+	"Increasing steadily or unsteadily",
+	"Decreasing or steady, then increasing; or increasing, then increasing more rapidly",
+	"Steady; pressure the same as 3 hours ago",
+	"Decreasing, then increasing; pressure the same or lower than 3 hours ago",
+	"Decreasing, then steady; or decreasing, then decreasing more slowly",
+	"Decreasing steadily or unsteadily",
+	"Steady or increasing, then decreasing; or decreasing, then decreasing more rapidly",
+}
+
+// ComputeTendency returns the tendency ending at the latest of readings,
+// which must span the last three hours.
+func ComputeTendency(readings []Reading) (*Tendency, error) {
+	if len(readings) == 0 {
+		return nil, fmt.Errorf("no pressure readings")
+	}
+	sort.Slice(readings, func(i, j int) bool { return readings[i].Time.Before(readings[j].Time) })
+
+	end := readings[len(readings)-1].Time
+	start := end.Add(-TendencyPeriod)
+
+	p0, ok := pressureAt(readings, start)
+	if !ok {
+		return nil, fmt.Errorf("no pressure readings from around %s", start.Format(time.RFC3339))
+	}
+	p1, ok := pressureAt(readings, end.Add(-TendencyPeriod/2))
+	if !ok {
+		return nil, fmt.Errorf("no pressure readings from around %s", end.Add(-TendencyPeriod/2).Format(time.RFC3339))
+	}
+	p2, _ := pressureAt(readings, end)
+
+	change := p2 - p0
+	code := wmoCode(p1-p0, p2-p1, change)
+
+	return &Tendency{
+		Time:           end,
+		Pressure:       round(p2, 1),
+		Change:         round(change, 1),
+		Trend:          trend(change),
+		Description:    describe(change),
+		WMOCode:        code,
+		WMODescription: wmoDescriptions[code],
+	}, nil
+}
+
+// pressureAt averages the readings within sampleWindow of t, or failing that
+// returns the nearest reading within maxGap.
+func pressureAt(readings []Reading, t time.Time) (float64, bool) {
+	sum, n := 0.0, 0
+	var nearest *Reading
+	for i := range readings {
+		r := &readings[i]
+		gap := absDuration(r.Time.Sub(t))
+		if gap <= sampleWindow {
+			sum += r.Pressure
+			n++
+		}
+		if gap <= maxGap && (nearest == nil || gap < absDuration(nearest.Time.Sub(t))) {
+			nearest = r
+		}
+	}
+	if n > 0 {
+		return sum / float64(n), true
+	}
+	if nearest != nil {
+		return nearest.Pressure, true
+	}
+	return 0, false
+}
+
+// wmoCode classifies the curve from its change over the first and second
+// halves of the period, and overall.
+func wmoCode(first, second, total float64) int {
+	s1, s2, st := sign(first), sign(second), sign(total)
+
+	switch {
+	// Increasing, then ...
+	case s1 > 0 && s2 > 0:
+		return 2
+	case s1 > 0 && s2 == 0:
+		return 1
+	case s1 > 0 && st >= 0:
+		return 0
+	case s1 > 0:
+		return 8
+
+	// Steady, then ...
+	case s1 == 0 && s2 > 0:
+		return 3
+	case s1 == 0 && s2 < 0:
+		return 8
+	case s1 == 0 && st > 0:
+		return 2
+	case s1 == 0 && st < 0:
+		return 7
+	case s1 == 0:
+		return 4
+
+	// Decreasing, then ...
+	case s2 < 0:
+		return 7
+	case s2 == 0:
+		return 6
+	case st > 0:
+		return 3
+	default:
+		return 5
+	}
+}
+
+// trend is the coarse tendency the Zambretti forecaster uses: changes under
+// 1.6 hPa in three hours count as steady.
+func trend(change float64) string {
+	switch {
+	case change >= 1.6:
+		return "rising"
+	case change <= -1.6:
+		return "falling"
+	default:
+		return "steady"
+	}
+}
+
+// describe words the change the way shipping forecasts do.
+func describe(change float64) string {
+	direction := "rising"
+	if change < 0 {
+		direction = "falling"
+	}
+	switch magnitude := math.Abs(change); {
+	case magnitude < 0.1:
+		return "steady"
+	case magnitude < 1.6:
+		return direction + " slowly"
+	case magnitude < 3.6:
+		return direction
+	case magnitude < 6:
+		return direction + " quickly"
+	default:
+		return direction + " very rapidly"
+	}
+}
+
+func sign(change float64) int {
+	switch {
+	case change >= steadyChange:
+		return 1
+	case change <= -steadyChange:
+		return -1
+	default:
+		return 0
+	}
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+func round(v float64, decimals int) float64 {
+	scale := math.Pow(10, float64(decimals))
+	return math.Round(v*scale) / scale
+}
@@ -0,0 +1,36 @@
+package forecast
+
+import "testing"
+
+func TestWMOCode(t *testing.T) {
+	tests := []struct {
+		name                 string
+		first, second, total float64
+		want                 int
+	}{
+		{"rising then falling", 1.0, -0.5, 0.5, 0},
+		{"rising then steady", 1.0, 0, 1.0, 1},
+		{"rising", 1.0, 0.5, 1.5, 2},
+		{"rising then falling, lower", 0.5, -1.0, -0.5, 8},
+		{"steady then rising", 0, 1.0, 1.0, 3},
+		{"steady then rising, net unchanged", -0.09, 0.15, 0.06, 3},
+		{"steady then falling", 0, -1.0, -1.0, 8},
+		{"steady then falling, net unchanged", 0.09, -0.15, -0.06, 8},
+		{"steady, slowly rising", 0.09, 0.09, 0.18, 2},
+		{"steady, slowly falling", -0.09, -0.09, -0.18, 7},
+		{"falling then rising, higher", -0.5, 1.0, 0.5, 3},
+		{"steady", 0.05, -0.05, 0, 4},
+		{"falling then rising, lower", -1.0, 0.5, -0.5, 5},
+		{"falling then rising, net unchanged", -0.5, 0.55, 0.05, 5},
+		{"falling then steady", -1.0, 0, -1.0, 6},
+		{"falling", -1.0, -0.5, -1.5, 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := wmoCode(tt.first, tt.second, tt.total); got != tt.want {
+				t.Errorf("wmoCode(%v, %v, %v) = %d, want %d", tt.first, tt.second, tt.total, got, tt.want)
+			}
+		})
+	}
+}
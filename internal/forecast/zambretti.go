@@ -0,0 +1,110 @@
+package forecast
+
+import (
+	"math"
+	"time"
+)
+
+// The Zambretti forecaster covers sea-level pressures from 950 to 1050 hPa,
+// split into 22 bands.
+const (
+	zambrettiBottom = 950.0
+	zambrettiTop    = 1050.0
+	zambrettiBands  = 22
+)
+
+var zambrettiForecasts = [26]string{
+	"Settled fine",
+	"Fine weather",
+	"Becoming fine",
+	"Fine, becoming less settled",
+	"Fine, possible showers",
+	"Fairly fine, improving",
+	"Fairly fine, possible showers early",
+	"Fairly fine, showery later",
+	"Showery early, improving",
+	"Changeable, mending",
+	"Fairly fine, showers likely",
+	"Rather unsettled, clearing later",
+	"Unsettled, probably improving",
+	"Showery, bright intervals",
+	"Showery, becoming less settled",
+	"Changeable, some rain",
+	"Unsettled, short fine intervals",
+	"Unsettled, rain later",
+	"Unsettled, some rain",
+	"Mostly very unsettled",
+	"Occasional rain, worsening",
+	"Rain at times, very unsettled",
+	"Rain at frequent intervals",
+	"Rain, very unsettled",
+	"Stormy, may improve",
+	"Stormy, much rain",
+}
+
+// Forecast indexes per pressure band, for each trend
+var (
+	zambrettiRising  = [zambrettiBands]int{25, 25, 25, 24, 24, 19, 16, 12, 11, 9, 8, 6, 5, 2, 1, 1, 0, 0, 0, 0, 0, 0}
+	zambrettiSteady  = [zambrettiBands]int{25, 25, 25, 25, 25, 25, 23, 23, 22, 18, 15, 13, 10, 4, 1, 1, 0, 0, 0, 0, 0, 0}
+	zambrettiFalling = [zambrettiBands]int{25, 25, 25, 25, 25, 25, 25, 25, 23, 23, 21, 20, 17, 14, 7, 3, 1, 1, 1, 0, 0, 0}
+)
+
+// windAdjustments shift the pressure, in percent of the range, for winds
+// from each of the 16 compass points, N first, in the northern hemisphere.
+var windAdjustments = [16]float64{6, 5, 5, 2, -0.5, -2, -5, -8.5, -12, -10, -6, -4.5, -3, -0.5, 1.5, 3}
+
+// Zambretti is a forecast for the next 12 hours or so.
+type Zambretti struct {
+	Letter   string `json:"letter"`
+	Forecast string `json:"forecast"`
+}
+
+// ComputeZambretti forecasts from the sea-level pressure in hPa and its
+// trend ("rising", "falling" or "steady"). windDir is the wind direction in
+// degrees, or nil when calm. The season is taken from t, and north tells
+// which hemisphere the station is in.
+func ComputeZambretti(pressure float64, trend string, windDir *float64, t time.Time, north bool) Zambretti {
+	span := zambrettiTop - zambrettiBottom
+
+	if windDir != nil {
+		dir := *windDir
+		if !north {
+			dir += 180
+		}
+		point := int(math.Floor(math.Mod(dir, 360)/22.5+0.5)) % 16
+		pressure += windAdjustments[point] / 100 * span
+	}
+
+	// Summer lows and highs move faster
+	month := t.Month()
+	summer := month >= time.April && month <= time.September
+	if !north {
+		summer = !summer
+	}
+	if summer {
+		switch trend {
+		case "rising":
+			pressure += 7.0 / 100 * span
+		case "falling":
+			pressure -= 7.0 / 100 * span
+		}
+	}
+
+	band := int(math.Floor((pressure - zambrettiBottom) / (span / zambrettiBands)))
+	band = int(math.Max(0, math.Min(zambrettiBands-1, float64(band))))
+
+	var index int
+	switch trend {
+	case "rising":
+		index = zambrettiRising[band]
+	case "falling":
+		index = zambrettiFalling[band]
+	default:
+		index = zambrettiSteady[band]
+	}
+
+	return Zambretti{
+		Letter:   string(rune('A' + index)),
+		Forecast: zambrettiForecasts[index],
+	}
+}
@@ -0,0 +1,352 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+	"wsrepeater/internal/agro"
+	"wsrepeater/internal/archive"
+	"wsrepeater/internal/config"
+	"wsrepeater/internal/weather"
+)
+
+const (
+	agroDefaultDays = 30
+	agroMaxDays     = 366
+)
+
+// Keys under which daily rollups keep the agricultural indices of their day.
+const (
+	agroET0Key   = "agro_et0mm"
+	agroGDDKey   = "agro_gdd"
+	agroHDDKey   = "agro_hdd"
+	agroCDDKey   = "agro_cdd"
+	agroChillKey = "agro_chillhours"
+)
+
+type agroDay struct {
+	Date       string   `json:"date"`
+	Partial    bool     `json:"partial"`
+	TempMaxC   float64  `json:"tempMaxC"`
+	TempMinC   float64  `json:"tempMinC"`
+	ET0Mm      *float64 `json:"et0Mm"`
+	GDD        float64  `json:"gdd"`
+	HDD        float64  `json:"hdd"`
+	CDD        float64  `json:"cdd"`
+	ChillHours int      `json:"chillHours"`
+}
+
+type agroTotals struct {
+	Start      string  `json:"start"`
+	Days       int     `json:"days"`
+	ET0Mm      float64 `json:"et0Mm"`
+	ET0Days    int     `json:"et0Days"`
+	GDD        float64 `json:"gdd"`
+	HDD        float64 `json:"hdd"`
+	CDD        float64 `json:"cdd"`
+	ChillHours int     `json:"chillHours"`
+}
+
+type agroSettings struct {
+	GDDBaseC         float64 `json:"gddBaseC"`
+	GDDCapC          float64 `json:"gddCapC"`
+	DegreeDayBaseC   float64 `json:"degreeDayBaseC"`
+	WindSensorHeight float64 `json:"windSensorHeight"`
+}
+
+type agroSummary struct {
+	Time        time.Time    `json:"time"`
+	Days        []agroDay    `json:"days"`
+	Season      agroTotals   `json:"season"`
+	ChillSeason agroTotals   `json:"chillSeason"`
+	Settings    agroSettings `json:"settings"`
+}
+
+// GetAgro serves daily reference evapotranspiration, growing, heating and
+// cooling degree days and chill hours, with their totals since the start of
+// the growing and chill seasons. The days query parameter sets how many days
+// are listed. The indices of a completed day are stored in its daily rollup
+// by AccumulateAgro, and the season totals add them up. Today, and days
+// rolled up before the indices were stored, are computed on read; today is
+// listed as partial but left out of the totals.
+func GetAgro(w http.ResponseWriter, r *http.Request) {
+	if archiveStore == nil {
+		http.Error(w, "local archive is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	n := agroDefaultDays
+	if v := r.URL.Query().Get("days"); v != "" {
+		days, err := strconv.Atoi(v)
+		if err != nil || days < 1 || days > agroMaxDays {
+			http.Error(w, "days must be between 1 and 366", http.StatusBadRequest)
+			return
+		}
+		n = days
+	}
+
+	settings := loadAgroSettings()
+
+	now := time.Now()
+	season := seasonStart(now, config.GetString("AGRO_SEASON_START", "04-01"))
+	chillSeason := seasonStart(now, config.GetString("CHILL_SEASON_START", "09-01"))
+	from := localMidnight(now, -n+1)
+	for _, start := range []time.Time{season, chillSeason} {
+		if start.Before(from) {
+			from = start
+		}
+	}
+
+	days, err := agroDays(from, now, settings)
+	if err != nil {
+		log.Printf("Error reading archive for agricultural statistics: %v", err)
+		http.Error(w, "error reading archive", http.StatusInternalServerError)
+		return
+	}
+
+	summary := agroSummary{
+		Time:        now,
+		Days:        []agroDay{},
+		Season:      agroTotals{Start: season.Format("2006-01-02")},
+		ChillSeason: agroTotals{Start: chillSeason.Format("2006-01-02")},
+		Settings:    settings,
+	}
+	first := localMidnight(now, -n+1).Format("2006-01-02")
+	for _, day := range days {
+		if day.Date >= first {
+			summary.Days = append(summary.Days, day)
+		}
+		if day.Partial {
+			continue
+		}
+		if day.Date >= summary.Season.Start {
+			summary.Season.add(day)
+		}
+		if day.Date >= summary.ChillSeason.Start {
+			summary.ChillSeason.add(day)
+		}
+	}
+	summary.Season.round()
+	summary.ChillSeason.round()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
+
+// AccumulateAgro stores the agricultural indices of a completed day in its
+// daily rollup, counting chill hours from the hourly rollups of the day. It
+// is the archive's daily hook; a day keeps the indices computed with the
+// settings of when it was rolled up.
+func AccumulateAgro(day *archive.Aggregate, hours []*archive.Aggregate) {
+	chill := 0
+	for _, hour := range hours {
+		if isChillHour(hour) {
+			chill++
+		}
+	}
+
+	d, ok := computeAgroDay(day, chill, loadAgroSettings())
+	if !ok {
+		return
+	}
+	if d.ET0Mm != nil {
+		day.Set(agroET0Key, *d.ET0Mm)
+	}
+	day.Set(agroGDDKey, d.GDD)
+	day.Set(agroHDDKey, d.HDD)
+	day.Set(agroCDDKey, d.CDD)
+	day.Set(agroChillKey, float64(d.ChillHours))
+}
+
+// agroDays returns the indices of every archived day from the day starting
+// at from to today: those stored in the daily rollups, or for days without
+// them, computed from the daily rollup and counting chill hours from the
+// hourly ones.
+func agroDays(from, now time.Time, settings agroSettings) ([]agroDay, error) {
+	daily, err := archiveStore.Summaries(archive.Daily, from, now.Add(time.Second))
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the days without stored indices need their hours
+	var pending time.Time
+	for _, agg := range daily {
+		if agg.Stat(agroGDDKey) == nil {
+			pending = agg.Start
+			break
+		}
+	}
+
+	loc := config.StationTimezone()
+	chill := make(map[string]int)
+	if !pending.IsZero() {
+		hourly, err := archiveStore.Summaries(archive.Hourly, pending, now.Add(time.Second))
+		if err != nil {
+			return nil, err
+		}
+		for _, hour := range hourly {
+			if isChillHour(hour) {
+				chill[hour.Start.In(loc).Format("2006-01-02")]++
+			}
+		}
+	}
+
+	today := localMidnight(now, 0)
+	var days []agroDay
+	for _, agg := range daily {
+		start := agg.Start.In(loc)
+		day, ok := storedAgroDay(agg)
+		if !ok {
+			day, ok = computeAgroDay(agg, chill[start.Format("2006-01-02")], settings)
+		}
+		if !ok {
+			continue
+		}
+		day.Partial = !start.Before(today)
+		days = append(days, day)
+	}
+	return days, nil
+}
+
+// storedAgroDay reads the indices AccumulateAgro stored in a daily rollup.
+func storedAgroDay(agg *archive.Aggregate) (agroDay, bool) {
+	temp := agg.Stat("tempf")
+	if temp == nil || agg.Stat(agroGDDKey) == nil {
+		return agroDay{}, false
+	}
+
+	day := agroDay{
+		Date:     agg.Start.In(config.StationTimezone()).Format("2006-01-02"),
+		TempMaxC: round1(weather.FahrenheitToCelsius(temp.Max)),
+		TempMinC: round1(weather.FahrenheitToCelsius(temp.Min)),
+		GDD:      agg.Stat(agroGDDKey).Last,
+	}
+	if stat := agg.Stat(agroET0Key); stat != nil {
+		day.ET0Mm = &stat.Last
+	}
+	if stat := agg.Stat(agroHDDKey); stat != nil {
+		day.HDD = stat.Last
+	}
+	if stat := agg.Stat(agroCDDKey); stat != nil {
+		day.CDD = stat.Last
+	}
+	if stat := agg.Stat(agroChillKey); stat != nil {
+		day.ChillHours = int(stat.Last)
+	}
+	return day, true
+}
+
+// computeAgroDay computes the indices of the day of a daily aggregate, which
+// had chill chill hours.
+func computeAgroDay(agg *archive.Aggregate, chill int, settings agroSettings) (agroDay, bool) {
+	temp := agg.Stat("tempf")
+	if temp == nil {
+		return agroDay{}, false
+	}
+	start := agg.Start.In(config.StationTimezone())
+	input := agro.Day{
+		DayOfYear:   start.YearDay(),
+		TempMaxC:    weather.FahrenheitToCelsius(temp.Max),
+		TempMinC:    weather.FahrenheitToCelsius(temp.Min),
+		SolarWm2:    meanOf(agg, "solarradiation"),
+		PressureHPa: meanOf(agg, "baromabsin"),
+	}
+	if stat := agg.Stat("humidity"); stat != nil {
+		input.HumidityMax, input.HumidityMin = &stat.Max, &stat.Min
+	}
+	if speed := meanOf(agg, "windspeedmph"); speed != nil {
+		ms := weather.MphToMs(*speed)
+		input.WindMs = &ms
+	}
+	if input.PressureHPa != nil {
+		hPa := weather.InHgToHPa(*input.PressureHPa)
+		input.PressureHPa = &hPa
+	}
+
+	day := agroDay{
+		Date:       start.Format("2006-01-02"),
+		TempMaxC:   round1(input.TempMaxC),
+		TempMinC:   round1(input.TempMinC),
+		GDD:        round1(agro.GrowingDegreeDays(input.TempMaxC, input.TempMinC, settings.GDDBaseC, settings.GDDCapC)),
+		HDD:        round1(agro.HeatingDegreeDays(input.TempMaxC, input.TempMinC, settings.DegreeDayBaseC)),
+		CDD:        round1(agro.CoolingDegreeDays(input.TempMaxC, input.TempMinC, settings.DegreeDayBaseC)),
+		ChillHours: chill,
+	}
+	if config.HasStationLocation() {
+		lat, _ := config.StationLocation()
+		if et0, ok := agro.ET0(input, lat, settings.WindSensorHeight); ok {
+			et0 = math.Round(et0*100) / 100
+			day.ET0Mm = &et0
+		}
+	}
+	return day, true
+}
+
+func loadAgroSettings() agroSettings {
+	return agroSettings{
+		GDDBaseC:         config.GetFloat("GDD_BASE_C", 10),
+		GDDCapC:          config.GetFloat("GDD_CAP_C", 30),
+		DegreeDayBaseC:   config.GetFloat("DEGREE_DAY_BASE_C", 18),
+		WindSensorHeight: config.GetFloat("WIND_SENSOR_HEIGHT", 2),
+	}
+}
+
+// isChillHour reports whether an hourly aggregate counts as a chill hour.
+func isChillHour(hour *archive.Aggregate) bool {
+	stat := hour.Stat("tempf")
+	return stat != nil && agro.IsChillHour(weather.FahrenheitToCelsius(stat.Avg()))
+}
+
+func (t *agroTotals) add(day agroDay) {
+	t.Days++
+	if day.ET0Mm != nil {
+		t.ET0Mm += *day.ET0Mm
+		t.ET0Days++
+	}
+	t.GDD += day.GDD
+	t.HDD += day.HDD
+	t.CDD += day.CDD
+	t.ChillHours += day.ChillHours
+}
+
+func (t *agroTotals) round() {
+	t.ET0Mm = math.Round(t.ET0Mm*10) / 10
+	t.GDD = round1(t.GDD)
+	t.HDD = round1(t.HDD)
+	t.CDD = round1(t.CDD)
+}
+
+// seasonStart returns the most recent local midnight falling on monthDay,
+// given as MM-DD, at or before now. An unparsable date starts the season on
+// January 1st.
+func seasonStart(now time.Time, monthDay string) time.Time {
+	md, err := time.Parse("01-02", monthDay)
+	if err != nil {
+		log.Printf("Invalid season start %q, using 01-01", monthDay)
+		md = time.Date(0, time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	local := now.In(config.StationTimezone())
+	start := time.Date(local.Year(), md.Month(), md.Day(), 0, 0, 0, 0, local.Location())
+	if start.After(now) {
+		start = start.AddDate(-1, 0, 0)
+	}
+	return start
+}
+
+// meanOf returns the mean of key over agg, or nil if it wasn't reported.
+func meanOf(agg *archive.Aggregate, key string) *float64 {
+	stat := agg.Stat(key)
+	if stat == nil || stat.Count == 0 {
+		return nil
+	}
+	avg := stat.Avg()
+	return &avg
+}
+
+func round1(v float64) float64 {
+	return math.Round(v*10) / 10
+}
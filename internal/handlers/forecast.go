@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+	"wsrepeater/internal/config"
+	"wsrepeater/internal/forecast"
+	"wsrepeater/internal/weather"
+	"wsrepeater/internal/wind"
+)
+
+const (
+	// windAveragePeriod is averaged for the wind direction the forecast
+	// uses.
+	windAveragePeriod = 10 * time.Minute
+	// maxForecastAge is how old the latest pressure reading may be.
+	maxForecastAge = 30 * time.Minute
+)
+
+type localForecast struct {
+	Tendency      *forecast.Tendency `json:"tendency"`
+	Zambretti     forecast.Zambretti `json:"zambretti"`
+	WindDirection *float64           `json:"windDirection"`
+}
+
+// GetLocalForecast serves the 3-hour pressure tendency and a Zambretti
+// forecast computed from the archived observations, so the dashboard has a
+// forecast even when the Environment Canada feed is unavailable.
+func GetLocalForecast(w http.ResponseWriter, r *http.Request) {
+	if archiveStore == nil {
+		http.Error(w, "local archive is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	now := time.Now()
+	observations, err := archiveStore.Range(now.Add(-forecast.TendencyPeriod-maxForecastAge), now.Add(time.Second))
+	if err != nil {
+		log.Printf("Error reading archive for forecast: %v", err)
+		http.Error(w, "error reading archive", http.StatusInternalServerError)
+		return
+	}
+
+	var readings []forecast.Reading
+	for _, obs := range observations {
+		if obs.BaromRelIn != nil {
+			readings = append(readings, forecast.Reading{Time: obs.Time, Pressure: weather.InHgToHPa(*obs.BaromRelIn)})
+		}
+	}
+
+	tendency, err := forecast.ComputeTendency(readings)
+	if err != nil {
+		http.Error(w, "not enough pressure history: "+err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if now.Sub(tendency.Time) > maxForecastAge {
+		http.Error(w, "no recent pressure readings", http.StatusServiceUnavailable)
+		return
+	}
+
+	windDir := recentWindDirection(observations, tendency.Time)
+	lat, _ := config.StationLocation()
+
+	response := localForecast{
+		Tendency:      tendency,
+		Zambretti:     forecast.ComputeZambretti(tendency.Pressure, tendency.Trend, windDir, now.In(config.StationTimezone()), lat >= 0),
+		WindDirection: windDir,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// recentWindDirection returns the vector-averaged wind direction over the
+// windAveragePeriod before end, or nil if the wind was calm.
+func recentWindDirection(observations []*weather.Observation, end time.Time) *float64 {
+	return wind.Summarize(observations, end.Add(-windAveragePeriod), end).Direction
+}
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+	"wsrepeater/internal/archive"
+	"wsrepeater/internal/config"
+	"wsrepeater/internal/weather"
+)
+
+// wuObservation mirrors one entry of the Weather Underground PWS
+// observations and history APIs.
+type wuObservation struct {
+	StationID          string   `json:"stationID"`
+	Tz                 string   `json:"tz"`
+	ObsTimeUtc         string   `json:"obsTimeUtc"`
+	ObsTimeLocal       string   `json:"obsTimeLocal"`
+	Epoch              int64    `json:"epoch"`
+	Lat                float64  `json:"lat"`
+	Lon                float64  `json:"lon"`
+	SolarRadiationHigh *float64 `json:"solarRadiationHigh"`
+	UvHigh             *float64 `json:"uvHigh"`
+	WinddirAvg         *float64 `json:"winddirAvg"`
+	HumidityHigh       *float64 `json:"humidityHigh"`
+	HumidityLow        *float64 `json:"humidityLow"`
+	HumidityAvg        *float64 `json:"humidityAvg"`
+	QcStatus           int      `json:"qcStatus"`
+	Imperial           *wuUnits `json:"imperial,omitempty"`
+	Metric             *wuUnits `json:"metric,omitempty"`
+}
+
+type wuUnits struct {
+	TempHigh      *float64 `json:"tempHigh"`
+	TempLow       *float64 `json:"tempLow"`
+	TempAvg       *float64 `json:"tempAvg"`
+	WindspeedHigh *float64 `json:"windspeedHigh"`
+	WindspeedLow  *float64 `json:"windspeedLow"`
+	WindspeedAvg  *float64 `json:"windspeedAvg"`
+	WindgustHigh  *float64 `json:"windgustHigh"`
+	WindgustLow   *float64 `json:"windgustLow"`
+	WindgustAvg   *float64 `json:"windgustAvg"`
+	DewptHigh     *float64 `json:"dewptHigh"`
+	DewptLow      *float64 `json:"dewptLow"`
+	DewptAvg      *float64 `json:"dewptAvg"`
+	PressureMax   *float64 `json:"pressureMax"`
+	PressureMin   *float64 `json:"pressureMin"`
+	PressureTrend *float64 `json:"pressureTrend"`
+	PrecipRate    *float64 `json:"precipRate"`
+	PrecipTotal   *float64 `json:"precipTotal"`
+}
+
+// statValue converts one statistic of the field key in agg, or returns nil if
+// the field had no samples in the interval.
+func statValue(agg *archive.Aggregate, key string, pick func(*archive.Stat) float64, conv func(float64) float64) *float64 {
+	stat := agg.Stat(key)
+	if stat == nil {
+		return nil
+	}
+	v := pick(stat)
+	if conv != nil {
+		v = conv(v)
+	}
+	return &v
+}
+
+func statMax(s *archive.Stat) float64   { return s.Max }
+func statMin(s *archive.Stat) float64   { return s.Min }
+func statAvg(s *archive.Stat) float64   { return s.Avg() }
+func statLast(s *archive.Stat) float64  { return s.Last }
+func statTrend(s *archive.Stat) float64 { return s.Last - s.First }
+
+func aggregateUnits(agg *archive.Aggregate, metric bool) *wuUnits {
+	var temp, speed, pressure, precip func(float64) float64
+	if metric {
+		temp, speed, pressure, precip = weather.FahrenheitToCelsius, weather.MphToKmh, weather.InHgToHPa, weather.InchesToMm
+	}
+
+	return &wuUnits{
+		TempHigh:      statValue(agg, "tempf", statMax, temp),
+		TempLow:       statValue(agg, "tempf", statMin, temp),
+		TempAvg:       statValue(agg, "tempf", statAvg, temp),
+		WindspeedHigh: statValue(agg, "windspeedmph", statMax, speed),
+		WindspeedLow:  statValue(agg, "windspeedmph", statMin, speed),
+		WindspeedAvg:  statValue(agg, "windspeedmph", statAvg, speed),
+		WindgustHigh:  statValue(agg, "windgustmph", statMax, speed),
+		WindgustLow:   statValue(agg, "windgustmph", statMin, speed),
+		WindgustAvg:   statValue(agg, "windgustmph", statAvg, speed),
+		DewptHigh:     statValue(agg, archive.DewPointKey, statMax, temp),
+		DewptLow:      statValue(agg, archive.DewPointKey, statMin, temp),
+		DewptAvg:      statValue(agg, archive.DewPointKey, statAvg, temp),
+		PressureMax:   statValue(agg, "baromrelin", statMax, pressure),
+		PressureMin:   statValue(agg, "baromrelin", statMin, pressure),
+		PressureTrend: statValue(agg, "baromrelin", statTrend, pressure),
+		PrecipRate:    statValue(agg, "rainratein", statLast, precip),
+		PrecipTotal:   statValue(agg, "dailyrainin", statLast, precip),
+	}
+}
+
+func aggregateObservation(agg *archive.Aggregate, loc *time.Location, metric bool) wuObservation {
+	lat, lon := config.StationLocation()
+
+	obs := wuObservation{
+		StationID:          os.Getenv("WUNDERGROUND_ID"),
+		Tz:                 loc.String(),
+		ObsTimeUtc:         agg.End.UTC().Format(time.RFC3339),
+		ObsTimeLocal:       agg.End.In(loc).Format("2006-01-02 15:04:05"),
+		Epoch:              agg.End.Unix(),
+		Lat:                lat,
+		Lon:                lon,
+		SolarRadiationHigh: statValue(agg, "solarradiation", statMax, nil),
+		UvHigh:             statValue(agg, "uv", statMax, nil),
+		HumidityHigh:       statValue(agg, "humidity", statMax, nil),
+		HumidityLow:        statValue(agg, "humidity", statMin, nil),
+		HumidityAvg:        statValue(agg, "humidity", statAvg, nil),
+		QcStatus:           1,
+	}
+	if dir, ok := agg.Wind.Direction(); ok {
+		obs.WinddirAvg = &dir
+	}
+	if metric {
+		obs.Metric = aggregateUnits(agg, true)
+	} else {
+		obs.Imperial = aggregateUnits(agg, false)
+	}
+	return obs
+}
+
+// localObservations summarizes the archived observations between from and to
+// into Weather Underground style 5-minute entries, using the five-minute
+// rollups where they have been computed.
+func localObservations(from, to time.Time, metric bool) ([]wuObservation, error) {
+	if archiveStore == nil {
+		return nil, fmt.Errorf("local archive is not configured")
+	}
+
+	aggregates, err := archiveStore.Summaries(archive.FiveMinute, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("error reading archive: %v", err)
+	}
+
+	loc := config.StationTimezone()
+	observations := make([]wuObservation, 0, len(aggregates))
+	for _, agg := range aggregates {
+		observations = append(observations, aggregateObservation(agg, loc, metric))
+	}
+
+	return observations, nil
+}
+
+// localMidnight returns the start of the station's day containing t, shifted
+// by days.
+func localMidnight(t time.Time, days int) time.Time {
+	loc := config.StationTimezone()
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day()+days, 0, 0, 0, 0, loc)
+}
+
+// getLocal1DayObservations builds the response of the WU observations/all/1day
+// endpoint (imperial units) from the archive.
+func getLocal1DayObservations() (map[string]interface{}, error) {
+	now := time.Now()
+	observations, err := localObservations(localMidnight(now, 0), now.Add(time.Second), false)
+	if err != nil {
+		return nil, err
+	}
+	if len(observations) == 0 {
+		return nil, fmt.Errorf("no archived observations for today")
+	}
+
+	// Round-trip through JSON so callers see the same types as for WU responses
+	body, err := json.Marshal(map[string]interface{}{"observations": observations})
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling local observations: %v", err)
+	}
+
+	var observationsResponse map[string]interface{}
+	if err := json.Unmarshal(body, &observationsResponse); err != nil {
+		return nil, fmt.Errorf("error parsing local observations: %v", err)
+	}
+
+	return observationsResponse, nil
+}
+
+// getLocal7DayHistory builds the /weekly response from the archive: today's
+// observations followed by the six previous days, in metric units.
+func getLocal7DayHistory() ([]byte, error) {
+	now := time.Now()
+	weeklyData := make([][]wuObservation, 7)
+
+	for i := 0; i < 7; i++ {
+		from := localMidnight(now, -i)
+		to := localMidnight(now, -i+1)
+		observations, err := localObservations(from, to, true)
+		if err != nil {
+			return nil, err
+		}
+		weeklyData[i] = observations
+	}
+
+	finalResponse, err := json.Marshal(map[string]interface{}{
+		"weeklyData": weeklyData,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling local history response: %v", err)
+	}
+
+	return finalResponse, nil
+}
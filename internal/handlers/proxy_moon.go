@@ -93,7 +93,7 @@ func ProxyMoon(w http.ResponseWriter, r *http.Request) {
 func fetchMoonData() ([]byte, error) {
 	apiKey := os.Getenv("ASTRO_API_KEY")
 
-	observationsResponse, err := getCached1DayObservations()
+	observationsResponse, err := get1DayObservations()
 	if err != nil {
 		return nil, fmt.Errorf("Error getting 1-day observations data: %v", err)
 	}
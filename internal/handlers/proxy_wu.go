@@ -9,6 +9,7 @@ import (
 	"os"
 	"sync/atomic"
 	"time"
+	"wsrepeater/internal/config"
 )
 
 var (
@@ -31,7 +32,28 @@ func StartWUPrefetcher() {
 	}
 }
 
+// get1DayObservations returns today's observations from the configured
+// history source, either the local archive or the Weather Underground API.
+func get1DayObservations() (map[string]interface{}, error) {
+	if config.LocalHistory() {
+		return getLocal1DayObservations()
+	}
+	return getCached1DayObservations()
+}
+
+// get7DayHistory returns the weekly history from the configured history source.
+func get7DayHistory() ([]byte, error) {
+	if config.LocalHistory() {
+		return getLocal7DayHistory()
+	}
+	return getCached7DayHistory()
+}
+
 func prefetch() {
+	if config.LocalHistory() {
+		return
+	}
+
 	_, err := getCached1DayObservations()
 	if err != nil {
 		log.Printf("Error prefetching 1-day observations data: %v", err)
@@ -45,7 +67,7 @@ func prefetch() {
 
 func ProxyWUToday(w http.ResponseWriter, r *http.Request) {
 	stationID := os.Getenv("WUNDERGROUND_ID")
-	observationsResponse, err := getCached1DayObservations()
+	observationsResponse, err := get1DayObservations()
 	if err != nil {
 		log.Printf("Error getting 1-day observations data: %v", err)
 		http.Error(w, "Failed to fetch 1-day observations data", http.StatusInternalServerError)
@@ -191,27 +213,23 @@ func getCached1DayObservations() (map[string]interface{}, error) {
 }
 
 func updateExtremes(extremes map[string]float64, data map[string]interface{}, highKey, lowKey string) {
-	high := data[highKey].(float64)
-	low := data[lowKey].(float64)
-
-	if high > extremes[highKey] {
+	// Values are null while a sensor is offline
+	if high, ok := data[highKey].(float64); ok && high > extremes[highKey] {
 		extremes[highKey] = high
 	}
-	if low < extremes[lowKey] {
+	if low, ok := data[lowKey].(float64); ok && low < extremes[lowKey] {
 		extremes[lowKey] = low
 	}
 }
 
 func updateExtremeValue(extremes map[string]float64, data map[string]interface{}, key string) {
-	value := data[key].(float64)
-
-	if value > extremes[key] {
+	if value, ok := data[key].(float64); ok && value > extremes[key] {
 		extremes[key] = value
 	}
 }
 
 func ProxyWUHistory(w http.ResponseWriter, r *http.Request) {
-	historyResponse, err := getCached7DayHistory()
+	historyResponse, err := get7DayHistory()
 	if err != nil {
 		log.Printf("Error getting 7-day history data: %v", err)
 		http.Error(w, "Failed to fetch 7-day history data", http.StatusInternalServerError)
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"time"
+	"wsrepeater/internal/archive"
+	"wsrepeater/internal/config"
+	"wsrepeater/internal/rain"
+	"wsrepeater/internal/weather"
+)
+
+const (
+	// rainLookback is the raw history scanned for totals and events.
+	rainLookback = 72 * time.Hour
+	// dryDayThreshold is the least rain, in inches (0.2 mm), that makes a
+	// day wet.
+	dryDayThreshold = 0.2 / 25.4
+)
+
+type rainEvent struct {
+	Start           time.Time `json:"start"`
+	End             time.Time `json:"end"`
+	Ongoing         bool      `json:"ongoing"`
+	DurationMinutes float64   `json:"durationMinutes"`
+	TotalMm         float64   `json:"totalMm"`
+	MaxRateMm       float64   `json:"maxRateMm"`
+}
+
+type rainSummary struct {
+	Time             time.Time   `json:"time"`
+	RateMm           *float64    `json:"rateMm"`
+	Intensity        string      `json:"intensity"`
+	LastHourMm       float64     `json:"lastHourMm"`
+	Last24hMm        float64     `json:"last24hMm"`
+	Last48hMm        float64     `json:"last48hMm"`
+	MaxRate24hMm     float64     `json:"maxRate24hMm"`
+	CurrentEvent     *rainEvent  `json:"currentEvent"`
+	LastEvent        *rainEvent  `json:"lastEvent"`
+	Events           []rainEvent `json:"events"`
+	DryDays          int         `json:"dryDays"`
+	LongestDryStreak int         `json:"longestDryStreak"`
+	LastRainDay      *string     `json:"lastRainDay"`
+}
+
+// GetRain serves rainfall totals, rain events and dry-day streaks computed
+// from the archive, in millimetres.
+func GetRain(w http.ResponseWriter, r *http.Request) {
+	if archiveStore == nil {
+		http.Error(w, "local archive is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	now := time.Now()
+	observations, err := archiveStore.Range(now.Add(-rainLookback), now.Add(time.Second))
+	if err != nil {
+		log.Printf("Error reading archive for rain statistics: %v", err)
+		http.Error(w, "error reading archive", http.StatusInternalServerError)
+		return
+	}
+
+	increments := rain.Increments(observations)
+	summary := rainSummary{
+		Time:         now,
+		Intensity:    "none",
+		LastHourMm:   mm(rain.Total(increments, now.Add(-time.Hour), now)),
+		Last24hMm:    mm(rain.Total(increments, now.Add(-24*time.Hour), now)),
+		Last48hMm:    mm(rain.Total(increments, now.Add(-48*time.Hour), now)),
+		MaxRate24hMm: mm(rain.MaxRate(increments, now.Add(-24*time.Hour))),
+		Events:       []rainEvent{},
+	}
+
+	if n := len(observations); n > 0 && observations[n-1].RainRateIn != nil {
+		rate := mm(*observations[n-1].RainRateIn)
+		summary.RateMm = &rate
+		summary.Intensity = rain.Intensity(rate)
+	}
+
+	for _, e := range rain.Events(increments, config.GetDuration("RAIN_EVENT_GAP", 6*time.Hour), now) {
+		summary.Events = append(summary.Events, rainEvent{
+			Start:           e.Start,
+			End:             e.End,
+			Ongoing:         e.Ongoing,
+			DurationMinutes: math.Round(e.Duration().Minutes()),
+			TotalMm:         mm(e.Inches),
+			MaxRateMm:       mm(e.MaxRateIn),
+		})
+	}
+	// The last event is either still going on or the last finished one
+	completed := summary.Events
+	if n := len(completed); n > 0 && completed[n-1].Ongoing {
+		summary.CurrentEvent = &completed[n-1]
+		completed = completed[:n-1]
+	}
+	if n := len(completed); n > 0 {
+		summary.LastEvent = &completed[n-1]
+	}
+
+	days, err := dailyRain(now, config.GetInt("RAIN_DRY_LOOKBACK_DAYS", 90))
+	if err != nil {
+		log.Printf("Error reading daily rain totals: %v", err)
+		http.Error(w, "error reading archive", http.StatusInternalServerError)
+		return
+	}
+	summary.DryDays, summary.LongestDryStreak = rain.DryStreaks(days, dryDayThreshold)
+	for i := len(days) - 1; i >= 0; i-- {
+		if !days[i].Missing && days[i].Inches >= dryDayThreshold {
+			day := days[i].Day.Format("2006-01-02")
+			summary.LastRainDay = &day
+			break
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
+
+// dailyRain returns the rain of each of the last n days in the station's
+// time zone, today included. Days are summed from the rain increments of the
+// rollups rather than read from the console's daily counter, which may not
+// reset at local midnight.
+func dailyRain(now time.Time, n int) ([]rain.DayTotal, error) {
+	from := localMidnight(now, -n+1)
+	aggregates, err := archiveStore.Summaries(archive.Daily, from, now.Add(time.Second))
+	if err != nil {
+		return nil, err
+	}
+
+	loc := config.StationTimezone()
+	byDay := make(map[string]*archive.Stat, len(aggregates))
+	for _, agg := range aggregates {
+		if stat := agg.Stat(archive.RainKey); stat != nil {
+			byDay[agg.Start.In(loc).Format("2006-01-02")] = stat
+		}
+	}
+
+	days := make([]rain.DayTotal, 0, n)
+	for day := from; !day.After(now); day = localMidnight(day, 1) {
+		total := rain.DayTotal{Day: day, Missing: true}
+		if stat, ok := byDay[day.Format("2006-01-02")]; ok {
+			total.Inches, total.Missing = stat.Sum, false
+		}
+		days = append(days, total)
+	}
+	return days, nil
+}
+
+func mm(inches float64) float64 {
+	return math.Round(weather.InchesToMm(inches)*10) / 10
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"wsrepeater/internal/archive"
+	"wsrepeater/internal/weather"
+)
+
+// testArchive opens an empty archive in the UTC time zone for the handlers.
+func testArchive(t *testing.T) *archive.Store {
+	t.Helper()
+	t.Setenv("STATION_TIMEZONE", "UTC")
+
+	store, err := archive.Open(t.TempDir(), archive.Options{Location: time.UTC})
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetArchive(store)
+	t.Cleanup(func() {
+		SetArchive(nil)
+		store.Close()
+	})
+	return store
+}
+
+func TestGetRainLookback(t *testing.T) {
+	store := testArchive(t)
+
+	// Hourly reports of a yearly counter, with rain 78 and 30 hours ago
+	now := time.Now().Truncate(time.Hour)
+	total := 0.0
+	for h := 80; h >= 0; h-- {
+		switch h {
+		case 78:
+			total += 0.1
+		case 30:
+			total += 0.2
+		}
+		yearly := total
+		if err := store.Put(&weather.Observation{Time: now.Add(-time.Duration(h) * time.Hour), YearlyRainIn: &yearly}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	GetRain(w, httptest.NewRequest("GET", "/rain", nil))
+	var summary rainSummary
+	if err := json.NewDecoder(w.Body).Decode(&summary); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+
+	if len(summary.Events) != 1 {
+		t.Fatalf("events = %+v, want only the one within the last 72 hours", summary.Events)
+	}
+	if summary.LastEvent == nil || summary.LastEvent.TotalMm != 5.1 || summary.CurrentEvent != nil {
+		t.Errorf("last event = %+v, current = %+v, want a finished 5.1 mm event", summary.LastEvent, summary.CurrentEvent)
+	}
+	if summary.Last48hMm != 5.1 || summary.Last24hMm != 0 {
+		t.Errorf("last 24h, 48h = %v, %v mm, want 0, 5.1", summary.Last24hMm, summary.Last48hMm)
+	}
+
+	rainDay := now.Add(-30 * time.Hour).UTC()
+	if summary.LastRainDay == nil || *summary.LastRainDay != rainDay.Format("2006-01-02") {
+		t.Errorf("last rain day = %v, want %s", summary.LastRainDay, rainDay.Format("2006-01-02"))
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if want := int(today.Sub(time.Date(rainDay.Year(), rainDay.Month(), rainDay.Day(), 0, 0, 0, 0, time.UTC)).Hours() / 24); summary.DryDays != want {
+		t.Errorf("dry days = %d, want %d", summary.DryDays, want)
+	}
+}
@@ -2,31 +2,25 @@ package handlers
 
 import (
 	"encoding/json"
-	"fmt"
 	"io/ioutil"
 	"log"
-	"math"
 	"net/http"
 	"net/url"
-	"os"
-	"strconv"
 	"strings"
 	"sync"
-	"wsrepeater/internal/utils"
+	"wsrepeater/internal/archive"
+	"wsrepeater/internal/calibration"
+	"wsrepeater/internal/sinks"
+	"wsrepeater/internal/weather"
 )
 
-const wundergroundURL = "http://weatherstation.wunderground.com/weatherstation/updateweatherstation.php"
-const movingAverageWindow = 5
-const workerCount = 5
-
 var (
-	uvValues             []float64
-	solarRadiationValues []float64
-	uvMutex              sync.Mutex
-	solarMutex           sync.Mutex
-	latestData           map[string]string
-	dataMutex            sync.Mutex
-	jobQueue             = make(chan url.Values, 100)
+	latestData        map[string]string
+	latestObservation *weather.Observation
+	dataMutex         sync.Mutex
+	archiveStore      *archive.Store
+	dispatcher        *sinks.Dispatcher
+	calibrator        *calibration.Calibrator
 )
 
 func ConvertAndForward(w http.ResponseWriter, r *http.Request) {
@@ -49,99 +43,83 @@ func ConvertAndForward(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	uvValue, err := strconv.ParseFloat(ecowittData.Get("uv"), 64)
-	if err != nil {
-		log.Printf("Error parsing UV value: %v", err)
-		http.Error(w, "can't parse UV value", http.StatusBadRequest)
+	obs := weather.ParseEcowitt(ecowittData)
+	if obs.Empty() {
+		log.Printf("Report carries no measurements")
+		http.Error(w, "no valid measurements in report", http.StatusBadRequest)
 		return
 	}
+	if len(obs.Invalid) > 0 {
+		log.Printf("Dropping invalid fields from report: %s", strings.Join(obs.Invalid, ", "))
+	}
 
-	solarRadiationValue, err := strconv.ParseFloat(ecowittData.Get("solarradiation"), 64)
-	if err != nil {
-		log.Printf("Error parsing solar radiation value: %v", err)
-		http.Error(w, "can't parse solar radiation value", http.StatusBadRequest)
-		return
+	// The dashboard, the archive and the sinks all get the calibrated values,
+	// so that local data matches what the upstream services received.
+	forwarded := obs.Clone()
+	if calibrator != nil {
+		forwarded = calibrator.Apply(obs)
 	}
 
-	tempF, err := strconv.ParseFloat(ecowittData.Get("tempf"), 64)
-	if err != nil {
-		log.Printf("Error parsing temperature value: %v", err)
-		http.Error(w, "can't parse temperature value", http.StatusBadRequest)
-		return
+	go updateLatestData(forwarded)
+	go archiveObservation(forwarded)
+
+	if dispatcher != nil {
+		dispatcher.Dispatch(forwarded)
 	}
 
-	humidity, err := strconv.ParseFloat(ecowittData.Get("humidity"), 64)
-	if err != nil {
-		log.Printf("Error parsing humidity value: %v", err)
-		http.Error(w, "can't parse humidity value", http.StatusBadRequest)
+	w.WriteHeader(http.StatusAccepted)
+	w.Write([]byte("Data accepted for processing"))
+}
+
+// SetDispatcher sets the dispatcher that forwards observations to the
+// enabled sinks.
+func SetDispatcher(d *sinks.Dispatcher) {
+	dispatcher = d
+}
+
+// SetCalibrator sets the calibration applied to observations before they are
+// archived and forwarded.
+func SetCalibrator(c *calibration.Calibrator) {
+	calibrator = c
+}
+
+// SetArchive sets the store every ingested observation is persisted to.
+func SetArchive(store *archive.Store) {
+	archiveStore = store
+}
+
+func archiveObservation(obs *weather.Observation) {
+	if archiveStore == nil {
 		return
 	}
+	if err := archiveStore.Put(obs); err != nil {
+		log.Printf("Error archiving observation: %v", err)
+	}
+}
 
-	windSpeedValue, err := strconv.ParseFloat(ecowittData.Get("windspeedmph"), 64)
-	if err != nil {
-		log.Printf("Error parsing wind speed value: %v", err)
-		http.Error(w, "can't parse wind speed value", http.StatusBadRequest)
-		return
+// updateLatestData stores the calibrated observation obs, along with how its
+// solar radiation compares with the clear-sky model. /latest then shows the
+// same values, derived quantities included, as the sinks receive.
+func updateLatestData(obs *weather.Observation) {
+	values := obs.Values()
+	for key, value := range solarValues(obs) {
+		values[key] = value
 	}
 
-	tempC := (tempF - 32) * 5 / 9
-	dewPointC := utils.CalculateDewPoint(tempC, humidity)
-	dewPointF := dewPointC*9/5 + 32
-
-	smoothedUV := utils.SmoothValue(uvValue, &uvValues, &uvMutex)
-	smoothedSolarRadiation := utils.SmoothValue(solarRadiationValue, &solarRadiationValues, &solarMutex)
-
-	correctedUV := math.Round(smoothedUV * 0.94)
-	correctedSolarRadiation := smoothedSolarRadiation * 0.94
-
-	wundergroundID := os.Getenv("WUNDERGROUND_ID")
-	wundergroundPW := os.Getenv("WUNDERGROUND_PASS")
-	stationSoftware := os.Getenv("STATION_SOFTWARE")
-
-	wundergroundData := url.Values{}
-	wundergroundData.Set("ID", wundergroundID)
-	wundergroundData.Set("PASSWORD", wundergroundPW)
-	wundergroundData.Set("dateutc", ecowittData.Get("dateutc"))
-	wundergroundData.Set("tempf", ecowittData.Get("tempf"))
-	wundergroundData.Set("humidity", ecowittData.Get("humidity"))
-	wundergroundData.Set("dewptf", fmt.Sprintf("%.2f", dewPointF))
-	wundergroundData.Set("windspeedmph", fmt.Sprintf("%.2f", windSpeedValue))
-	wundergroundData.Set("windgustmph", ecowittData.Get("windgustmph"))
-	wundergroundData.Set("winddir", ecowittData.Get("winddir"))
-	wundergroundData.Set("solarradiation", fmt.Sprintf("%.2f", correctedSolarRadiation))
-	wundergroundData.Set("UV", fmt.Sprintf("%d", int(correctedUV)))
-	wundergroundData.Set("baromin", ecowittData.Get("baromrelin"))
-	wundergroundData.Set("absbaromin", ecowittData.Get("baromabsin"))
-	wundergroundData.Set("rainin", ecowittData.Get("rainratein"))
-	wundergroundData.Set("dailyrainin", ecowittData.Get("dailyrainin"))
-	wundergroundData.Set("weeklyrainin", ecowittData.Get("weeklyrainin"))
-	wundergroundData.Set("monthlyrainin", ecowittData.Get("monthlyrainin"))
-	wundergroundData.Set("yearlyrainin", ecowittData.Get("yearlyrainin"))
-	wundergroundData.Set("indoortempf", ecowittData.Get("tempinf"))
-	wundergroundData.Set("indoorhumidity", ecowittData.Get("humidityin"))
-	wundergroundData.Set("softwaretype", stationSoftware)
-	wundergroundData.Set("realtime", "1")
-	wundergroundData.Set("rtfreq", ecowittData.Get("interval"))
-	wundergroundData.Set("action", "updateraw")
-
-	go updateLatestData(ecowittData)
-
-	jobQueue <- wundergroundData
+	dataMutex.Lock()
+	defer dataMutex.Unlock()
 
-	w.WriteHeader(http.StatusAccepted)
-	w.Write([]byte("Data accepted for processing"))
+	latestObservation = obs
+	latestData = values
 }
 
-func updateLatestData(data url.Values) {
+// LatestObservation returns the most recent calibrated report received from
+// the gateway, or nil if none has been received yet.
+func LatestObservation() *weather.Observation {
 	dataMutex.Lock()
 	defer dataMutex.Unlock()
 
-	latestData = make(map[string]string)
-	for key, values := range data {
-		if len(values) > 0 {
-			latestData[key] = values[0]
-		}
-	}
+	return latestObservation
 }
 
 func GetLatestData(w http.ResponseWriter, r *http.Request) {
@@ -161,31 +139,3 @@ func GetLatestDataWithCORS(w http.ResponseWriter, r *http.Request) {
 
 	json.NewEncoder(w).Encode(latestData)
 }
-
-func StartWorkerPool() {
-	for i := 0; i < workerCount; i++ {
-		go worker()
-	}
-}
-
-func worker() {
-	for job := range jobQueue {
-		resp, err := http.PostForm(wundergroundURL, job)
-		if err != nil {
-			log.Printf("Error forwarding to Wunderground: %v", err)
-			continue
-		}
-		defer resp.Body.Close()
-
-		respBody, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			log.Printf("Error reading Wunderground response: %v", err)
-			continue
-		}
-
-		// if respBody is anything other than "success", log it
-		if !strings.Contains(string(respBody), "success") {
-			log.Printf("Wunderground response: %s", respBody)
-		}
-	}
-}
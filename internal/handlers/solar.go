@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"math"
+	"strconv"
+	"wsrepeater/internal/config"
+	"wsrepeater/internal/solar"
+	"wsrepeater/internal/weather"
+)
+
+// minCloudinessElevation is the sun elevation in degrees below which the
+// reading is dominated by horizon, shading and cosine errors, so no
+// cloudiness is estimated.
+const minCloudinessElevation = 10
+
+// solarValues compares the solar radiation of obs with the clear-sky model,
+// returning the theoretical maximum, a cloudiness estimate in percent and
+// whether the reading exceeds the maximum. It returns nil when the station
+// location is not configured.
+func solarValues(obs *weather.Observation) map[string]string {
+	if !config.HasStationLocation() {
+		return nil
+	}
+	lat, lon := config.StationLocation()
+
+	pressure := 0.0
+	if obs.BaromAbsIn != nil {
+		pressure = weather.InHgToHPa(*obs.BaromAbsIn)
+	}
+	elevation := solar.Elevation(obs.Time, lat, lon)
+	theoretical := solar.ClearSky(obs.Time, lat, lon, pressure, config.GetFloat("SOLAR_TRANSMITTANCE", solar.DefaultTransmittance))
+
+	values := map[string]string{
+		"solarElevation":   strconv.FormatFloat(elevation, 'f', 1, 64),
+		"solarTheoretical": strconv.FormatFloat(theoretical, 'f', 1, 64),
+	}
+	if obs.SolarRadiation == nil {
+		return values
+	}
+
+	measured := *obs.SolarRadiation
+	values["solarAboveTheoretical"] = strconv.FormatBool(measured > theoretical)
+	if elevation >= minCloudinessElevation && theoretical > 0 {
+		cloudiness := math.Max(0, math.Min(1, 1-measured/theoretical)) * 100
+		values["cloudinessEstimate"] = strconv.FormatFloat(cloudiness, 'f', 0, 64)
+	}
+	return values
+}
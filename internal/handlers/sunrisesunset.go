@@ -35,7 +35,7 @@ func prefetchSunriseSunset() {
 }
 
 func fetchAndCacheSunriseSunset() ([]byte, error) {
-	observationsResponse, err := getCached1DayObservations()
+	observationsResponse, err := get1DayObservations()
 	if err != nil {
 		return nil, fmt.Errorf("error getting 1-day observations data: %v", err)
 	}
@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+	"wsrepeater/internal/archive"
+	"wsrepeater/internal/config"
+	"wsrepeater/internal/weather"
+	"wsrepeater/internal/wind"
+)
+
+const windRoseDefaultDays = 7
+
+type windNow struct {
+	Time        time.Time    `json:"time"`
+	SpeedMs     *float64     `json:"speedMs"`
+	GustMs      *float64     `json:"gustMs"`
+	Direction   *float64     `json:"direction"`
+	Beaufort    *int         `json:"beaufort"`
+	Description string       `json:"description,omitempty"`
+	Avg2m       wind.Summary `json:"avg2m"`
+	Avg10m      wind.Summary `json:"avg10m"`
+}
+
+type windRoseSector struct {
+	Direction string    `json:"direction"`
+	Degrees   float64   `json:"degrees"`
+	Percent   float64   `json:"percent"`
+	Classes   []float64 `json:"classes"`
+}
+
+type windRose struct {
+	From        time.Time        `json:"from"`
+	To          time.Time        `json:"to"`
+	Resolution  string           `json:"resolution"`
+	Samples     int              `json:"samples"`
+	SpeedMs     *float64         `json:"speedMs"`
+	Direction   *float64         `json:"direction"`
+	GustMs      *float64         `json:"gustMs"`
+	GustFactor  *float64         `json:"gustFactor"`
+	Prevailing  string           `json:"prevailing,omitempty"`
+	CalmPercent float64          `json:"calmPercent"`
+	Classes     []string         `json:"classes"`
+	Sectors     []windRoseSector `json:"sectors"`
+}
+
+// GetWind serves the current wind with its 2- and 10-minute averages, in m/s.
+func GetWind(w http.ResponseWriter, r *http.Request) {
+	if archiveStore == nil {
+		http.Error(w, "local archive is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	now := time.Now()
+	observations, err := archiveStore.Range(now.Add(-10*time.Minute), now.Add(time.Second))
+	if err != nil {
+		log.Printf("Error reading archive for wind statistics: %v", err)
+		http.Error(w, "error reading archive", http.StatusInternalServerError)
+		return
+	}
+
+	response := windNow{
+		Time:   now,
+		Avg2m:  wind.Summarize(observations, now.Add(-2*time.Minute), now),
+		Avg10m: wind.Summarize(observations, now.Add(-10*time.Minute), now),
+	}
+	if n := len(observations); n > 0 {
+		latest := observations[n-1]
+		response.Time = latest.Time
+		response.Direction = latest.WindDir
+		if latest.WindSpeedMph != nil {
+			speed := round1(weather.MphToMs(*latest.WindSpeedMph))
+			force := wind.Beaufort(speed)
+			response.SpeedMs, response.Beaufort = &speed, &force
+			response.Description = wind.BeaufortDescription(force)
+		}
+		if latest.WindGustMph != nil {
+			gust := round1(weather.MphToMs(*latest.WindGustMph))
+			response.GustMs = &gust
+		}
+	}
+	roundSummary(&response.Avg2m)
+	roundSummary(&response.Avg10m)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetWindRose serves a wind rose of 16 sectors by speed class, in percent of
+// the observations, over the period given by the from and to query
+// parameters (RFC 3339 times or station dates) or the last days. It is built
+// from the finest archive rollups that still cover the period, each weighted
+// by its number of observations. Daily averages say little about where the
+// wind blew from, so periods older than the hourly rollups are refused.
+func GetWindRose(w http.ResponseWriter, r *http.Request) {
+	if archiveStore == nil {
+		http.Error(w, "local archive is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	now := time.Now()
+	from, to, err := windRosePeriod(r, now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	classes := wind.DefaultClasses
+	if v := config.GetString("WIND_ROSE_CLASSES", ""); v != "" {
+		if classes, err = wind.ParseClasses(v); err != nil {
+			log.Printf("Invalid WIND_ROSE_CLASSES, using defaults: %v", err)
+			classes = wind.DefaultClasses
+		}
+	}
+
+	tier := archiveStore.FinestTier(from, now)
+	if tier == archive.Daily {
+		http.Error(w, "period starts before the hourly rollups that are kept", http.StatusBadRequest)
+		return
+	}
+	aggregates, err := archiveStore.Summaries(tier, from, to)
+	if err != nil {
+		log.Printf("Error reading archive for wind rose: %v", err)
+		http.Error(w, "error reading archive", http.StatusInternalServerError)
+		return
+	}
+
+	rose := wind.NewRose(classes)
+	var speedSum, u, v float64
+	var speedCount, vectorCount int
+	var gust *float64
+	for _, agg := range aggregates {
+		speed := agg.Stat("windspeedmph")
+		if speed == nil {
+			continue
+		}
+		speedSum += speed.Sum
+		speedCount += speed.Count
+		u += agg.Wind.U
+		v += agg.Wind.V
+		vectorCount += agg.Wind.Count
+		if stat := agg.Stat("windgustmph"); stat != nil && (gust == nil || stat.Max > *gust) {
+			gust = &stat.Max
+		}
+
+		dir, _ := agg.Wind.Direction()
+		rose.Add(weather.MphToMs(speed.Avg()), dir, speed.Count)
+	}
+
+	response := windRose{
+		From:       from,
+		To:         to,
+		Resolution: tier.String(),
+		Samples:    rose.Total,
+		Sectors:    []windRoseSector{},
+	}
+	for i := range classes {
+		response.Classes = append(response.Classes, rose.ClassName(i))
+	}
+	if speedCount > 0 {
+		mean := weather.MphToMs(speedSum / float64(speedCount))
+		if vectorCount > 0 && mean >= wind.CalmMs {
+			dir := round1(math.Mod(math.Atan2(u, v)*180/math.Pi+360, 360))
+			response.Direction = &dir
+		}
+		if gust != nil {
+			gustMs := round1(weather.MphToMs(*gust))
+			response.GustMs = &gustMs
+			if factor, ok := wind.GustFactor(weather.MphToMs(*gust), mean); ok {
+				factor = math.Round(factor*100) / 100
+				response.GustFactor = &factor
+			}
+		}
+		rounded := round1(mean)
+		response.SpeedMs = &rounded
+	}
+	if rose.Total > 0 {
+		response.CalmPercent = percent(rose.Calm, rose.Total)
+		if sector := rose.Prevailing(); sector >= 0 {
+			response.Prevailing = wind.SectorName(sector)
+		}
+		for sector, counts := range rose.Counts {
+			s := windRoseSector{
+				Direction: wind.SectorName(sector),
+				Degrees:   float64(sector) * 360 / wind.Sectors,
+			}
+			total := 0
+			for _, c := range counts {
+				s.Classes = append(s.Classes, percent(c, rose.Total))
+				total += c
+			}
+			s.Percent = percent(total, rose.Total)
+			response.Sectors = append(response.Sectors, s)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// windRosePeriod returns the period requested with from and to, which
+// default to windRoseDefaultDays (or the days parameter) before now.
+func windRosePeriod(r *http.Request, now time.Time) (time.Time, time.Time, error) {
+	query := r.URL.Query()
+
+	to := now
+	if v := query.Get("to"); v != "" {
+		t, err := parsePeriodBound(v, true)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		to = t
+	}
+
+	days := windRoseDefaultDays
+	if v := query.Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid days %q", v)
+		}
+		days = n
+	}
+	from := to.AddDate(0, 0, -days)
+	if v := query.Get("from"); v != "" {
+		t, err := parsePeriodBound(v, false)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		from = t
+	}
+
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, fmt.Errorf("from must be before to")
+	}
+	return from, to, nil
+}
+
+// parsePeriodBound parses an RFC 3339 time or a date in the station's time
+// zone. A date used as the end of a period includes that whole day.
+func parsePeriodBound(s string, end bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, config.StationTimezone())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q", s)
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// roundSummary rounds a wind summary for display.
+func roundSummary(s *wind.Summary) {
+	s.SpeedMs = round1(s.SpeedMs)
+	s.VectorMs = round1(s.VectorMs)
+	for _, p := range []*float64{s.Direction, s.Variability, s.GustMs} {
+		if p != nil {
+			*p = math.Round(*p*10) / 10
+		}
+	}
+	if s.GustFactor != nil {
+		*s.GustFactor = math.Round(*s.GustFactor*100) / 100
+	}
+}
+
+func percent(n, total int) float64 {
+	return math.Round(float64(n)/float64(total)*1000) / 10
+}
@@ -12,6 +12,7 @@ import (
 	"sync/atomic"
 	"time"
 	"wsrepeater/internal/handlers"
+	"wsrepeater/internal/sinks"
 	"wsrepeater/internal/utils"
 )
 
@@ -24,11 +25,12 @@ type EndpointStats struct {
 }
 
 type Stats struct {
-	endpoints sync.Map
+	endpoints  sync.Map
+	dispatcher *sinks.Dispatcher
 }
 
-func NewStats() *Stats {
-	return &Stats{}
+func NewStats(dispatcher *sinks.Dispatcher) *Stats {
+	return &Stats{dispatcher: dispatcher}
 }
 
 func (s *Stats) Middleware(next http.Handler) http.Handler {
@@ -121,11 +123,19 @@ func (s *Stats) ServeStats(w http.ResponseWriter, r *http.Request) {
 		"WUHits":     strconv.FormatUint(wuHits, 10),
 	}
 
+	// Sink upload counters
+	var sinkStats []sinks.SinkStats
+	if s.dispatcher != nil {
+		sinkStats = s.dispatcher.Stats()
+		programStats["IngestDropped"] = strconv.FormatUint(s.dispatcher.IntakeDropped(), 10)
+	}
+
 	// JSON response
 	if jsonOutput {
 		stats := map[string]interface{}{
 			"endpoints":    endpointStats,
 			"programStats": programStats,
+			"sinks":        sinkStats,
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -173,6 +183,52 @@ func (s *Stats) ServeStats(w http.ResponseWriter, r *http.Request) {
 				{{ end }}
 			</table>
 
+			<h2>Sinks</h2>
+			<table>
+				<tr>
+					<th>Sink</th>
+					<th>Sent</th>
+					<th>Failed</th>
+					<th>Skipped</th>
+					<th>Dropped</th>
+					<th>Queued</th>
+					<th>Dead Letters</th>
+				</tr>
+				{{ range .Sinks }}
+				<tr>
+					<td>{{ .Name }}</td>
+					<td>{{ .Sent }}</td>
+					<td>{{ .Failed }}</td>
+					<td>{{ .Skipped }}</td>
+					<td>{{ .Dropped }} ({{ .Overflow }})</td>
+					<td>{{ .Queued }}</td>
+					<td>{{ .DeadLetters }}</td>
+				</tr>
+				{{ end }}
+			</table>
+
+			<h2>Upload Status</h2>
+			<table>
+				<tr>
+					<th>Sink</th>
+					<th>Last Success</th>
+					<th>Consecutive Failures</th>
+					<th>HTTP Status</th>
+					<th>Latency</th>
+					<th>Last Error</th>
+				</tr>
+				{{ range .Sinks }}
+				<tr>
+					<td>{{ .Name }}</td>
+					<td>{{ with .Status.LastSuccess }}{{ .Format "2006-01-02 15:04:05" }}{{ else }}never{{ end }}</td>
+					<td>{{ .Status.ConsecutiveFailures }}</td>
+					<td>{{ with .Status.HTTPStatus }}{{ . }}{{ end }}</td>
+					<td>{{ printf "%.0f ms" .Status.LatencyMs }}</td>
+					<td>{{ with .Status.LastFailure }}{{ .Format "2006-01-02 15:04:05" }}: {{ end }}{{ .Status.LastError }}</td>
+				</tr>
+				{{ end }}
+			</table>
+
 			<h2>Program</h2>
 			<table>
 				<tr><th>Alloc</th><td>{{ .ProgramStats.Alloc }}</td></tr>
@@ -184,6 +240,7 @@ func (s *Stats) ServeStats(w http.ResponseWriter, r *http.Request) {
 				<tr><th>Num CPU</th><td>{{ .ProgramStats.NumCPU }}</td></tr>
 				<tr><th>Uptime</th><td>{{ .ProgramStats.Uptime }}</td></tr>
 				<tr><th>WU Hits</th><td>{{ .ProgramStats.WUHits }}</td></tr>
+				{{ with .ProgramStats.IngestDropped }}<tr><th>Ingest Dropped</th><td>{{ . }}</td></tr>{{ end }}
 			</table>
 		</div>
 	</body>
@@ -200,13 +257,28 @@ func (s *Stats) ServeStats(w http.ResponseWriter, r *http.Request) {
 		Keys          []string
 		EndpointStats map[string]map[string]string
 		ProgramStats  map[string]interface{}
+		Sinks         []sinks.SinkStats
 	}{
 		Keys:          keys,
 		EndpointStats: endpointStats,
 		ProgramStats:  programStats,
+		Sinks:         sinkStats,
 	}
 
 	if err := t.Execute(w, data); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
+
+// ServeSinkStatus serves the upload counters and status of every sink as JSON.
+func (s *Stats) ServeSinkStatus(w http.ResponseWriter, r *http.Request) {
+	sinkStats := []sinks.SinkStats{}
+	if s.dispatcher != nil {
+		sinkStats = s.dispatcher.Stats()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(sinkStats); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
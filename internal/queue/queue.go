@@ -0,0 +1,351 @@
+package queue
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+	"wsrepeater/internal/weather"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const fileName = "queue.db"
+
+// Options configures the retry behaviour of a queue.
+type Options struct {
+	// MaxAge is how long an entry is retried before it is dead-lettered.
+	MaxAge time.Duration
+	// MaxAttempts dead-letters an entry after that many failed uploads;
+	// zero retries until MaxAge.
+	MaxAttempts int
+	// MinBackoff and MaxBackoff bound the exponential delay between retries.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// DeadRetention is how long dead-lettered entries are kept for inspection.
+	DeadRetention time.Duration
+}
+
+// Queue is a persistent per-sink queue of observations waiting to be
+// uploaded. Once pushed, an entry stays on disk until it is uploaded or
+// dead-lettered, so it survives both service outages and restarts.
+type Queue struct {
+	db   *bolt.DB
+	opts Options
+}
+
+// Entry is one queued upload.
+type Entry struct {
+	Observation *weather.Observation `json:"observation"`
+	Enqueued    time.Time            `json:"enqueued"`
+	Attempts    int                  `json:"attempts"`
+	NextAttempt time.Time            `json:"nextAttempt"`
+	LastError   string               `json:"lastError,omitempty"`
+
+	key []byte
+}
+
+// Open opens (or creates) the queue file in dir.
+func Open(dir string, opts Options) (*Queue, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("error creating data directory: %v", err)
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, fileName), 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("error opening queue: %v", err)
+	}
+
+	return &Queue{db: db, opts: opts}, nil
+}
+
+func (q *Queue) Close() error {
+	return q.db.Close()
+}
+
+func pendingBucket(sink string) []byte {
+	return []byte("pending_" + sink)
+}
+
+func deadBucket(sink string) []byte {
+	return []byte("dead_" + sink)
+}
+
+// Overflow decides what happens when an observation is pushed to a full queue.
+type Overflow int
+
+const (
+	// DropOldest discards the oldest queued entries to make room.
+	DropOldest Overflow = iota
+	// DropNewest discards the observation being pushed.
+	DropNewest
+	// Coalesce keeps only the latest observation, whatever the capacity.
+	// It suits sinks that only care about current conditions.
+	Coalesce
+)
+
+// ParseOverflow parses "drop-oldest", "drop-newest" or "coalesce".
+func ParseOverflow(s string) (Overflow, error) {
+	switch s {
+	case "drop-oldest":
+		return DropOldest, nil
+	case "drop-newest":
+		return DropNewest, nil
+	case "coalesce":
+		return Coalesce, nil
+	default:
+		return 0, fmt.Errorf("unknown overflow policy %q, expected drop-oldest, drop-newest or coalesce", s)
+	}
+}
+
+func (o Overflow) String() string {
+	switch o {
+	case DropNewest:
+		return "drop-newest"
+	case Coalesce:
+		return "coalesce"
+	default:
+		return "drop-oldest"
+	}
+}
+
+// Push appends obs to the queue of sink. When the queue already holds
+// capacity entries (zero means unbounded), overflow decides what is dropped.
+// It returns how many observations were dropped.
+func (q *Queue) Push(sink string, obs *weather.Observation, capacity int, overflow Overflow) (int, error) {
+	now := time.Now()
+	entry := &Entry{Observation: obs, Enqueued: now, NextAttempt: now}
+	dropped := 0
+
+	err := q.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(pendingBucket(sink))
+		if err != nil {
+			return err
+		}
+
+		// Walk back from the newest entry past the ones that still fit beside
+		// obs, so that a push never has to count a long backlog
+		keep := -1
+		switch {
+		case overflow == Coalesce:
+			keep = 0
+		case capacity > 0:
+			keep = capacity - 1
+		}
+
+		if keep >= 0 {
+			c := b.Cursor()
+			k, _ := c.Last()
+			for i := 0; k != nil && i < keep; i++ {
+				k, _ = c.Prev()
+			}
+			if k != nil && overflow == DropNewest {
+				dropped = 1
+				return nil
+			}
+
+			var keys [][]byte
+			for ; k != nil; k, _ = c.Prev() {
+				keys = append(keys, append([]byte(nil), k...))
+			}
+			for _, k := range keys {
+				if err := b.Delete(k); err != nil {
+					return err
+				}
+			}
+			dropped = len(keys)
+		}
+
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		return putEntry(b, seqKey(seq), entry)
+	})
+
+	return dropped, err
+}
+
+// Peek returns the oldest entry queued for sink, or nil if there is none.
+func (q *Queue) Peek(sink string) (*Entry, error) {
+	entries, err := q.PeekN(sink, 1)
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	return entries[0], nil
+}
+
+// PeekN returns up to n of the oldest entries queued for sink, oldest first.
+// Entries that can't be decoded are discarded so they can't block the queue.
+func (q *Queue) PeekN(sink string, n int) ([]*Entry, error) {
+	var entries []*Entry
+
+	err := q.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(pendingBucket(sink))
+		if b == nil {
+			return nil
+		}
+
+		var corrupt [][]byte
+		c := b.Cursor()
+		for k, v := c.First(); k != nil && len(entries) < n; k, v = c.Next() {
+			e, err := decodeEntry(k, v)
+			if err != nil {
+				log.Printf("Discarding queue entry for %s: %v", sink, err)
+				corrupt = append(corrupt, append([]byte(nil), k...))
+				continue
+			}
+			entries = append(entries, e)
+		}
+		for _, k := range corrupt {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return entries, err
+}
+
+// Ack removes entries that were uploaded successfully.
+func (q *Queue) Ack(sink string, entries ...*Entry) error {
+	return q.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(pendingBucket(sink))
+		if b == nil {
+			return nil
+		}
+		for _, entry := range entries {
+			if err := b.Delete(entry.key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Retry records a failed upload of entry and schedules the next attempt with
+// exponential backoff. It returns true if the entry was dead-lettered instead
+// because it ran out of attempts or grew older than MaxAge.
+func (q *Queue) Retry(sink string, entry *Entry, uploadErr error, now time.Time) (bool, error) {
+	entry.Attempts++
+	entry.LastError = uploadErr.Error()
+
+	if q.Expired(entry, now) || (q.opts.MaxAttempts > 0 && entry.Attempts >= q.opts.MaxAttempts) {
+		return true, q.DeadLetter(sink, entry)
+	}
+
+	entry.NextAttempt = now.Add(q.backoff(entry.Attempts))
+
+	return false, q.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(pendingBucket(sink))
+		// The entry may have been dropped by an overflow while in flight
+		if b == nil || b.Get(entry.key) == nil {
+			return nil
+		}
+		return putEntry(b, entry.key, entry)
+	})
+}
+
+// Expired reports whether entry is older than MaxAge.
+func (q *Queue) Expired(entry *Entry, now time.Time) bool {
+	return q.opts.MaxAge > 0 && now.Sub(entry.Enqueued) > q.opts.MaxAge
+}
+
+// DeadLetter moves entry out of the pending queue into the dead-letter area
+// of sink, and drops dead letters older than DeadRetention or that can't be
+// decoded.
+func (q *Queue) DeadLetter(sink string, entry *Entry) error {
+	return q.db.Update(func(tx *bolt.Tx) error {
+		if b := tx.Bucket(pendingBucket(sink)); b != nil {
+			if err := b.Delete(entry.key); err != nil {
+				return err
+			}
+		}
+
+		dead, err := tx.CreateBucketIfNotExists(deadBucket(sink))
+		if err != nil {
+			return err
+		}
+		if err := putEntry(dead, entry.key, entry); err != nil {
+			return err
+		}
+
+		if q.opts.DeadRetention <= 0 {
+			return nil
+		}
+		cutoff := time.Now().Add(-q.opts.DeadRetention)
+		var expired [][]byte
+		c := dead.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			e, err := decodeEntry(k, v)
+			if err != nil {
+				log.Printf("Discarding dead letter for %s: %v", sink, err)
+			} else if e.Enqueued.After(cutoff) {
+				break
+			}
+			expired = append(expired, append([]byte(nil), k...))
+		}
+		for _, k := range expired {
+			if err := dead.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Len returns the number of pending and dead-lettered entries of sink.
+func (q *Queue) Len(sink string) (pending, dead int) {
+	q.db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket(pendingBucket(sink)); b != nil {
+			pending = b.Stats().KeyN
+		}
+		if b := tx.Bucket(deadBucket(sink)); b != nil {
+			dead = b.Stats().KeyN
+		}
+		return nil
+	})
+	return pending, dead
+}
+
+// backoff returns the delay before retry number attempts.
+func (q *Queue) backoff(attempts int) time.Duration {
+	delay := q.opts.MinBackoff
+	if delay <= 0 {
+		delay = time.Second
+	}
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if q.opts.MaxBackoff > 0 && delay >= q.opts.MaxBackoff {
+			return q.opts.MaxBackoff
+		}
+	}
+	return delay
+}
+
+func putEntry(b *bolt.Bucket, key []byte, entry *Entry) error {
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("error encoding queue entry: %v", err)
+	}
+	return b.Put(key, value)
+}
+
+func decodeEntry(k, v []byte) (*Entry, error) {
+	entry := &Entry{key: append([]byte(nil), k...)}
+	if err := json.Unmarshal(v, entry); err != nil {
+		return nil, fmt.Errorf("error decoding queue entry %x: %v", k, err)
+	}
+	return entry, nil
+}
+
+// seqKey encodes a bucket sequence so that byte order matches queue order.
+func seqKey(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return key
+}
@@ -0,0 +1,253 @@
+package queue
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"wsrepeater/internal/weather"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func openTest(t *testing.T, opts Options) *Queue {
+	t.Helper()
+	q, err := Open(t.TempDir(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { q.Close() })
+	return q
+}
+
+func observationAt(minute int) *weather.Observation {
+	return &weather.Observation{Time: time.Date(2026, time.January, 1, 0, minute, 0, 0, time.UTC)}
+}
+
+// minutes returns the minute of each queued observation, oldest first.
+func minutes(t *testing.T, q *Queue, sink string) []int {
+	t.Helper()
+	entries, err := q.PeekN(sink, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []int
+	for _, e := range entries {
+		got = append(got, e.Observation.Time.Minute())
+	}
+	return got
+}
+
+func equal(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPushOverflow(t *testing.T) {
+	tests := []struct {
+		overflow    Overflow
+		want        []int
+		wantDropped int
+	}{
+		{DropOldest, []int{2, 3, 4}, 2},
+		{DropNewest, []int{0, 1, 2}, 2},
+		{Coalesce, []int{4}, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.overflow.String(), func(t *testing.T) {
+			q := openTest(t, Options{})
+			dropped := 0
+			for minute := 0; minute < 5; minute++ {
+				n, err := q.Push("sink", observationAt(minute), 3, tt.overflow)
+				if err != nil {
+					t.Fatal(err)
+				}
+				dropped += n
+			}
+
+			if got := minutes(t, q, "sink"); !equal(got, tt.want) {
+				t.Errorf("queued %v, want %v", got, tt.want)
+			}
+			if dropped != tt.wantDropped {
+				t.Errorf("dropped %d, want %d", dropped, tt.wantDropped)
+			}
+		})
+	}
+}
+
+func TestPushShrunkCapacity(t *testing.T) {
+	q := openTest(t, Options{})
+	for minute := 0; minute < 5; minute++ {
+		q.Push("sink", observationAt(minute), 0, DropOldest)
+	}
+
+	dropped, err := q.Push("sink", observationAt(5), 2, DropOldest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := minutes(t, q, "sink"); !equal(got, []int{4, 5}) || dropped != 4 {
+		t.Errorf("queued %v after dropping %d, want [4 5] after dropping 4", got, dropped)
+	}
+}
+
+func TestPushUnbounded(t *testing.T) {
+	q := openTest(t, Options{})
+	for minute := 0; minute < 5; minute++ {
+		if _, err := q.Push("sink", observationAt(minute), 0, DropOldest); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if pending, _ := q.Len("sink"); pending != 5 {
+		t.Errorf("pending = %d, want 5", pending)
+	}
+	if pending, _ := q.Len("other"); pending != 0 {
+		t.Errorf("other sink has %d pending entries", pending)
+	}
+}
+
+func TestPeekAck(t *testing.T) {
+	q := openTest(t, Options{})
+	for minute := 0; minute < 3; minute++ {
+		q.Push("sink", observationAt(minute), 0, DropOldest)
+	}
+
+	entries, err := q.PeekN("sink", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Ack("sink", entries...); err != nil {
+		t.Fatal(err)
+	}
+
+	entry, err := q.Peek("sink")
+	if err != nil || entry == nil {
+		t.Fatalf("Peek = %v, %v", entry, err)
+	}
+	if entry.Observation.Time.Minute() != 2 {
+		t.Errorf("oldest entry after ack is from minute %d, want 2", entry.Observation.Time.Minute())
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	q := openTest(t, Options{MinBackoff: 10 * time.Second, MaxBackoff: time.Minute})
+	q.Push("sink", observationAt(0), 0, DropOldest)
+
+	now := time.Now()
+	want := []time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second, time.Minute, time.Minute}
+	for i, delay := range want {
+		entry, _ := q.Peek("sink")
+		dead, err := q.Retry("sink", entry, errors.New("down"), now)
+		if err != nil || dead {
+			t.Fatalf("Retry = %v, %v", dead, err)
+		}
+
+		entry, _ = q.Peek("sink")
+		if entry.Attempts != i+1 {
+			t.Errorf("attempts = %d, want %d", entry.Attempts, i+1)
+		}
+		if got := entry.NextAttempt.Sub(now); got != delay {
+			t.Errorf("attempt %d: next attempt in %v, want %v", i+1, got, delay)
+		}
+		if entry.LastError != "down" {
+			t.Errorf("last error = %q", entry.LastError)
+		}
+	}
+}
+
+func TestRetryDeadLetters(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		now  time.Time
+	}{
+		{"max attempts", Options{MaxAttempts: 1}, time.Now()},
+		{"max age", Options{MaxAge: time.Hour}, time.Now().Add(2 * time.Hour)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := openTest(t, tt.opts)
+			q.Push("sink", observationAt(0), 0, DropOldest)
+
+			entry, _ := q.Peek("sink")
+			dead, err := q.Retry("sink", entry, errors.New("down"), tt.now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !dead {
+				t.Fatal("entry was rescheduled, want dead-lettered")
+			}
+			if pending, deadLetters := q.Len("sink"); pending != 0 || deadLetters != 1 {
+				t.Errorf("pending, dead = %d, %d, want 0, 1", pending, deadLetters)
+			}
+		})
+	}
+}
+
+func TestRetryAfterOverflow(t *testing.T) {
+	q := openTest(t, Options{})
+	q.Push("sink", observationAt(0), 0, DropOldest)
+	inFlight, _ := q.Peek("sink")
+
+	// Coalescing replaces the entry while its upload is in flight
+	q.Push("sink", observationAt(1), 0, Coalesce)
+	if _, err := q.Retry("sink", inFlight, errors.New("down"), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := minutes(t, q, "sink"); !equal(got, []int{1}) {
+		t.Errorf("queued %v, want the dropped entry to stay dropped", got)
+	}
+}
+
+func TestDeadLetterRetention(t *testing.T) {
+	q := openTest(t, Options{DeadRetention: time.Hour})
+	q.Push("sink", observationAt(0), 0, DropOldest)
+	old, _ := q.Peek("sink")
+	old.Enqueued = time.Now().Add(-2 * time.Hour)
+	if err := q.DeadLetter("sink", old); err != nil {
+		t.Fatal(err)
+	}
+
+	q.Push("sink", observationAt(1), 0, DropOldest)
+	recent, _ := q.Peek("sink")
+	if err := q.DeadLetter("sink", recent); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, dead := q.Len("sink"); dead != 1 {
+		t.Errorf("dead = %d, want only the recent dead letter kept", dead)
+	}
+}
+
+func TestDeadLetterDiscardsCorrupt(t *testing.T) {
+	q := openTest(t, Options{DeadRetention: time.Hour})
+	err := q.db.Update(func(tx *bolt.Tx) error {
+		dead, err := tx.CreateBucketIfNotExists(deadBucket("sink"))
+		if err != nil {
+			return err
+		}
+		return dead.Put(seqKey(0), []byte("{"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q.Push("sink", observationAt(0), 0, DropOldest)
+	old, _ := q.Peek("sink")
+	old.Enqueued = time.Now().Add(-2 * time.Hour)
+	if err := q.DeadLetter("sink", old); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, dead := q.Len("sink"); dead != 0 {
+		t.Errorf("dead = %d, want the corrupt and the expired dead letters dropped", dead)
+	}
+}
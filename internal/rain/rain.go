@@ -0,0 +1,202 @@
+// Package rain turns the Ecowitt rain counters into rainfall amounts, rain
+// events and intensity statistics. The counters reset on the console's own
+// schedule and when the gateway reboots, so rainfall is taken from the
+// increments between consecutive reports rather than from any one counter.
+package rain
+
+import (
+	"sort"
+	"time"
+	"wsrepeater/internal/weather"
+)
+
+const (
+	// maxRateInPerHour bounds a plausible increment; larger jumps between two
+	// reports are counter glitches, such as a console restoring old totals.
+	maxRateInPerHour = 12.0
+	// resetTolerance absorbs rounding in the reported counters.
+	resetTolerance = 0.001
+)
+
+// counters in order of preference: the less often a counter resets, the
+// fewer increments have to be reconstructed.
+var counters = []string{"totalrainin", "yearlyrainin", "monthlyrainin", "weeklyrainin", "dailyrainin", "eventrainin", "hourlyrainin"}
+
+// Increment is the rain that fell between the previous report and Time.
+type Increment struct {
+	Time   time.Time
+	Inches float64
+	// RateIn is the rain rate reported at Time, in in/h, if any
+	RateIn *float64
+	// EventReset is set when the console's event counter went back to zero,
+	// closing its rain event.
+	EventReset bool
+}
+
+// Increments returns one increment per observation after the first, which
+// must be sorted by time.
+func Increments(observations []*weather.Observation) []Increment {
+	increments := make([]Increment, 0, len(observations))
+	for i := 1; i < len(observations); i++ {
+		prev, cur := observations[i-1], observations[i]
+		inc := Increment{Time: cur.Time, RateIn: cur.RainRateIn, Inches: Delta(prev, cur)}
+
+		if p, ok := prev.Get("eventrainin"); ok && p > 0 {
+			if c, ok := cur.Get("eventrainin"); ok && c == 0 {
+				inc.EventReset = true
+			}
+		}
+		increments = append(increments, inc)
+	}
+	return increments
+}
+
+// Delta returns the rain between two reports, from the first counter both
+// carry. A counter that went down was reset, so everything it shows fell
+// since the reset.
+func Delta(prev, cur *weather.Observation) float64 {
+	for _, key := range counters {
+		p, ok := prev.Get(key)
+		if !ok {
+			continue
+		}
+		c, ok := cur.Get(key)
+		if !ok {
+			continue
+		}
+
+		d := c - p
+		if d < -resetTolerance {
+			d = c
+		}
+		if d <= resetTolerance {
+			return 0
+		}
+
+		hours := cur.Time.Sub(prev.Time).Hours()
+		if d > maxRateInPerHour*hours+0.1 {
+			return 0
+		}
+		return d
+	}
+	return 0
+}
+
+// Total sums the increments after from and up to to.
+func Total(increments []Increment, from, to time.Time) float64 {
+	total := 0.0
+	for _, inc := range increments {
+		if inc.Time.After(from) && !inc.Time.After(to) {
+			total += inc.Inches
+		}
+	}
+	return total
+}
+
+// MaxRate returns the highest reported rain rate after from, in in/h.
+func MaxRate(increments []Increment, from time.Time) float64 {
+	max := 0.0
+	for _, inc := range increments {
+		if inc.Time.After(from) && inc.RateIn != nil && *inc.RateIn > max {
+			max = *inc.RateIn
+		}
+	}
+	return max
+}
+
+// Event is a period of rain.
+type Event struct {
+	Start time.Time
+	// End is the time of the last rain, so far for an ongoing event
+	End       time.Time
+	Ongoing   bool
+	Inches    float64
+	MaxRateIn float64
+}
+
+// Duration is how long it rained.
+func (e *Event) Duration() time.Duration {
+	return e.End.Sub(e.Start)
+}
+
+// Events splits the increments into rain events. An event starts with the
+// first rain, or rain rate, and ends once it has been dry for gap or when
+// the console closes its own event. The last event is ongoing if it rained
+// within gap of now.
+func Events(increments []Increment, gap time.Duration, now time.Time) []Event {
+	var events []Event
+	var current *Event
+
+	for _, inc := range increments {
+		if inc.EventReset && current != nil {
+			current = nil
+		}
+
+		wet := inc.Inches > 0 || (inc.RateIn != nil && *inc.RateIn > 0)
+		if !wet {
+			continue
+		}
+		if current != nil && inc.Time.Sub(current.End) > gap {
+			current = nil
+		}
+		if current == nil {
+			events = append(events, Event{Start: inc.Time, End: inc.Time})
+			current = &events[len(events)-1]
+		}
+
+		current.End = inc.Time
+		current.Inches += inc.Inches
+		if inc.RateIn != nil && *inc.RateIn > current.MaxRateIn {
+			current.MaxRateIn = *inc.RateIn
+		}
+	}
+
+	if n := len(events); n > 0 && current == &events[n-1] && now.Sub(current.End) <= gap {
+		current.Ongoing = true
+	}
+	return events
+}
+
+// Intensity classifies a rain rate in mm/h (American Meteorological Society).
+func Intensity(rateMm float64) string {
+	switch {
+	case rateMm <= 0:
+		return "none"
+	case rateMm < 2.5:
+		return "light"
+	case rateMm < 7.6:
+		return "moderate"
+	case rateMm < 50:
+		return "heavy"
+	default:
+		return "violent"
+	}
+}
+
+// DayTotal is the rain of one day, in inches. Days without any report are
+// Missing, and can't be counted as dry.
+type DayTotal struct {
+	Day     time.Time
+	Inches  float64
+	Missing bool
+}
+
+// DryStreaks returns the number of consecutive dry days up to and including
+// the last of days, and the longest run of dry days among them. A day is dry
+// with less than threshold inches.
+func DryStreaks(days []DayTotal, threshold float64) (current, longest int) {
+	sort.Slice(days, func(i, j int) bool { return days[i].Day.Before(days[j].Day) })
+
+	run := 0
+	for _, day := range days {
+		if !day.Missing && day.Inches < threshold {
+			run++
+		} else {
+			run = 0
+		}
+		if run > longest {
+			longest = run
+		}
+	}
+	return run, longest
+}
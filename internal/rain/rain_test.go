@@ -0,0 +1,179 @@
+package rain
+
+import (
+	"net/url"
+	"testing"
+	"time"
+	"wsrepeater/internal/weather"
+)
+
+// report builds an observation at minute past midnight from Ecowitt fields.
+func report(minute int, fields map[string]string) *weather.Observation {
+	values := url.Values{}
+	values.Set("dateutc", time.Date(2026, time.January, 1, 0, minute, 0, 0, time.UTC).Format("2006-01-02 15:04:05"))
+	for key, value := range fields {
+		values.Set(key, value)
+	}
+	return weather.ParseEcowitt(values)
+}
+
+func TestDelta(t *testing.T) {
+	tests := []struct {
+		name      string
+		prev, cur map[string]string
+		want      float64
+	}{
+		{"increment", map[string]string{"dailyrainin": "0.10"}, map[string]string{"dailyrainin": "0.15"}, 0.05},
+		{"no rain", map[string]string{"dailyrainin": "0.10"}, map[string]string{"dailyrainin": "0.10"}, 0},
+		{"reset", map[string]string{"dailyrainin": "0.50"}, map[string]string{"dailyrainin": "0.02"}, 0.02},
+		{"reset to zero", map[string]string{"dailyrainin": "0.50"}, map[string]string{"dailyrainin": "0"}, 0},
+		{"implausible jump", map[string]string{"yearlyrainin": "1.0"}, map[string]string{"yearlyrainin": "20.0"}, 0},
+		{"no common counter", map[string]string{"dailyrainin": "0.10"}, map[string]string{"weeklyrainin": "0.30"}, 0},
+		{
+			"prefers the counter that resets least",
+			map[string]string{"yearlyrainin": "10.00", "dailyrainin": "0.50"},
+			map[string]string{"yearlyrainin": "10.04", "dailyrainin": "0"},
+			0.04,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Delta(report(0, tt.prev), report(5, tt.cur))
+			if diff := got - tt.want; diff > 1e-9 || diff < -1e-9 {
+				t.Errorf("Delta = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvents(t *testing.T) {
+	start := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+	rate := func(in float64) *float64 { return &in }
+
+	tests := []struct {
+		name       string
+		increments []Increment
+		now        time.Time
+		want       []Event
+	}{
+		{
+			"dry",
+			[]Increment{{Time: at(10)}, {Time: at(20)}},
+			at(30),
+			nil,
+		},
+		{
+			"one event",
+			[]Increment{{Time: at(10), Inches: 0.02}, {Time: at(20)}, {Time: at(30), Inches: 0.03, RateIn: rate(0.4)}},
+			at(24 * 60),
+			[]Event{{Start: at(10), End: at(30), Inches: 0.05, MaxRateIn: 0.4}},
+		},
+		{
+			"split by a dry gap",
+			[]Increment{{Time: at(10), Inches: 0.02}, {Time: at(10 + 7*60), Inches: 0.01}},
+			at(24 * 60),
+			[]Event{
+				{Start: at(10), End: at(10), Inches: 0.02},
+				{Start: at(10 + 7*60), End: at(10 + 7*60), Inches: 0.01},
+			},
+		},
+		{
+			"dry spell shorter than the gap",
+			[]Increment{{Time: at(10), Inches: 0.02}, {Time: at(10 + 5*60), Inches: 0.01}},
+			at(24 * 60),
+			[]Event{{Start: at(10), End: at(10 + 5*60), Inches: 0.03}},
+		},
+		{
+			"closed by the console",
+			[]Increment{{Time: at(10), Inches: 0.02}, {Time: at(20), EventReset: true}, {Time: at(30), Inches: 0.01}},
+			at(24 * 60),
+			[]Event{
+				{Start: at(10), End: at(10), Inches: 0.02},
+				{Start: at(30), End: at(30), Inches: 0.01},
+			},
+		},
+		{
+			"drizzle under the counter resolution",
+			[]Increment{{Time: at(10), RateIn: rate(0.01)}},
+			at(24 * 60),
+			[]Event{{Start: at(10), End: at(10), MaxRateIn: 0.01}},
+		},
+		{
+			"ongoing",
+			[]Increment{{Time: at(10), Inches: 0.02}},
+			at(60),
+			[]Event{{Start: at(10), End: at(10), Inches: 0.02, Ongoing: true}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Events(tt.increments, 6*time.Hour, tt.now)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Events = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("event %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestEventsAcrossCounterReset(t *testing.T) {
+	// The console resets its daily counter at midnight, mid-event
+	observations := []*weather.Observation{
+		report(-20, map[string]string{"dailyrainin": "0.40"}),
+		report(-10, map[string]string{"dailyrainin": "0.45"}),
+		report(0, map[string]string{"dailyrainin": "0.02"}),
+		report(10, map[string]string{"dailyrainin": "0.06"}),
+	}
+
+	increments := Increments(observations)
+	events := Events(increments, 6*time.Hour, observations[3].Time.Add(24*time.Hour))
+	if len(events) != 1 {
+		t.Fatalf("Events = %+v, want one event", events)
+	}
+	if diff := events[0].Inches - 0.11; diff > 1e-9 || diff < -1e-9 {
+		t.Errorf("event rain = %v, want 0.11", events[0].Inches)
+	}
+	if total := Total(increments, observations[0].Time, observations[3].Time); total != events[0].Inches {
+		t.Errorf("Total = %v, want the event's %v", total, events[0].Inches)
+	}
+}
+
+func TestDryStreaks(t *testing.T) {
+	day := func(n int, inches float64) DayTotal {
+		return DayTotal{Day: time.Date(2026, time.January, n, 0, 0, 0, 0, time.UTC), Inches: inches}
+	}
+	missing := func(n int) DayTotal {
+		d := day(n, 0)
+		d.Missing = true
+		return d
+	}
+
+	tests := []struct {
+		name             string
+		days             []DayTotal
+		current, longest int
+	}{
+		{"no days", nil, 0, 0},
+		{"all dry", []DayTotal{day(1, 0), day(2, 0), day(3, 0.001)}, 3, 3},
+		{"rained today", []DayTotal{day(1, 0), day(2, 0), day(3, 0.5)}, 0, 2},
+		{"longest in the past", []DayTotal{day(1, 0), day(2, 0), day(3, 0), day(4, 0.2), day(5, 0)}, 1, 3},
+		{"missing day breaks the streak", []DayTotal{day(1, 0), missing(2), day(3, 0)}, 1, 1},
+		{"unsorted", []DayTotal{day(3, 0), day(1, 0.5), day(2, 0)}, 2, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current, longest := DryStreaks(tt.days, 0.2/25.4)
+			if current != tt.current || longest != tt.longest {
+				t.Errorf("DryStreaks = %d, %d, want %d, %d", current, longest, tt.current, tt.longest)
+			}
+		})
+	}
+}
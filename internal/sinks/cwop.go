@@ -0,0 +1,216 @@
+package sinks
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"strings"
+	"time"
+	"wsrepeater/internal/config"
+	"wsrepeater/internal/weather"
+)
+
+const (
+	cwopServer = "cwop.aprs.net:14580"
+
+	// CWOP asks stations not to report more often than every 5 minutes
+	cwopMinInterval = 5 * time.Minute
+
+	// APRS software type character and name appended to every packet
+	cwopSoftware = "wsrepeater"
+)
+
+func init() {
+	Register("cwop", newCWOP)
+}
+
+// CWOP uploads observations to the Citizen Weather Observer Program as APRS
+// weather packets sent over TCP to an APRS-IS server.
+type CWOP struct {
+	server     string
+	callsign   string
+	passcode   string
+	lat, lon   float64
+	positioned bool
+	timeout    time.Duration
+}
+
+func newCWOP() (Sink, error) {
+	callsign := strings.ToUpper(os.Getenv("CWOP_CALLSIGN"))
+	if callsign == "" {
+		return nil, fmt.Errorf("CWOP_CALLSIGN must be set")
+	}
+
+	lat, lon := config.StationLocation()
+
+	return &CWOP{
+		server:   config.GetString("CWOP_SERVER", cwopServer),
+		callsign: callsign,
+		// CW stations without a ham license log in with passcode -1
+		passcode:   config.GetString("CWOP_PASSCODE", "-1"),
+		lat:        lat,
+		lon:        lon,
+		positioned: config.HasStationLocation(),
+		timeout:    30 * time.Second,
+	}, nil
+}
+
+func (s *CWOP) Name() string {
+	return "cwop"
+}
+
+func (s *CWOP) MinInterval() time.Duration {
+	return cwopMinInterval
+}
+
+func (s *CWOP) Send(obs *weather.Observation) error {
+	packet := s.packet(obs)
+
+	conn, err := net.DialTimeout("tcp", s.server, s.timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(s.timeout))
+
+	reader := bufio.NewReader(conn)
+
+	// The server greets with a comment line before accepting the login
+	if _, err := reader.ReadString('\n'); err != nil {
+		return fmt.Errorf("error reading server banner: %v", err)
+	}
+
+	login := fmt.Sprintf("user %s pass %s vers %s 1.0\r\n", s.callsign, s.passcode, cwopSoftware)
+	if _, err := conn.Write([]byte(login)); err != nil {
+		return fmt.Errorf("error sending login: %v", err)
+	}
+
+	resp, err := reader.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("error reading login response: %v", err)
+	}
+	if !strings.Contains(resp, "logresp") {
+		return fmt.Errorf("unexpected login response: %s", strings.TrimSpace(resp))
+	}
+
+	if _, err := conn.Write([]byte(packet + "\r\n")); err != nil {
+		return fmt.Errorf("error sending packet: %v", err)
+	}
+
+	return nil
+}
+
+// packet formats obs as an APRS weather report. With a configured station
+// location it is a positioned report with timestamp:
+//
+//	CALL>APRS,TCPIP*:@DDHHMMzDDMM.mmN/DDDMM.mmW_ddd/sssgggtTTTrRRRPPPPhHHbBBBBB
+//
+// and otherwise a positionless report starting with _MMDDHHMM. Unknown
+// values are sent as dots, as the APRS specification requires.
+func (s *CWOP) packet(obs *weather.Observation) string {
+	t := obs.Time.UTC()
+
+	var b strings.Builder
+	b.WriteString(s.callsign)
+	b.WriteString(">APRS,TCPIP*:")
+
+	if s.positioned {
+		b.WriteString(t.Format("@021504z"))
+		b.WriteString(aprsLatitude(s.lat))
+		b.WriteString("/")
+		b.WriteString(aprsLongitude(s.lon))
+		b.WriteString("_")
+		b.WriteString(aprsField(obs.WindDir, 3, 1))
+		b.WriteString("/")
+		b.WriteString(aprsField(obs.WindSpeedMph, 3, 1))
+	} else {
+		b.WriteString(t.Format("_01021504"))
+		b.WriteString("c" + aprsField(obs.WindDir, 3, 1))
+		b.WriteString("s" + aprsField(obs.WindSpeedMph, 3, 1))
+	}
+
+	b.WriteString("g" + aprsField(obs.WindGustMph, 3, 1))
+	b.WriteString("t" + aprsField(obs.TempF, 3, 1))
+	if obs.HourlyRainIn != nil {
+		b.WriteString("r" + aprsField(obs.HourlyRainIn, 3, 100))
+	}
+	if obs.DailyRainIn != nil {
+		b.WriteString("P" + aprsField(obs.DailyRainIn, 3, 100))
+	}
+	if obs.Humidity != nil {
+		// Humidity is two digits, with 00 meaning 100%
+		h := int(math.Round(*obs.Humidity))
+		if h < 1 {
+			h = 1
+		}
+		if h >= 100 {
+			h = 0
+		}
+		fmt.Fprintf(&b, "h%02d", h)
+	}
+	if obs.BaromRelIn != nil {
+		// Tenths of a millibar
+		fmt.Fprintf(&b, "b%05d", int(math.Round(weather.InHgToHPa(*obs.BaromRelIn)*10)))
+	}
+	if obs.SolarRadiation != nil {
+		// L for 0-999 W/m², l for 1000 and above
+		l := int(math.Round(*obs.SolarRadiation))
+		if l < 1000 {
+			fmt.Fprintf(&b, "L%03d", l)
+		} else {
+			fmt.Fprintf(&b, "l%03d", l-1000)
+		}
+	}
+
+	b.WriteString("e" + cwopSoftware)
+	return b.String()
+}
+
+// aprsField formats p*scale as a zero-padded integer of width digits, or as
+// dots when the value is missing.
+func aprsField(p *float64, width int, scale float64) string {
+	if p == nil {
+		return strings.Repeat(".", width)
+	}
+	v := int(math.Round(*p * scale))
+	max := int(math.Pow10(width)) - 1
+	if v > max {
+		v = max
+	}
+	if v < 0 {
+		// Negative values keep the width by giving up a digit to the sign
+		return fmt.Sprintf("-%0*d", width-1, -v)
+	}
+	return fmt.Sprintf("%0*d", width, v)
+}
+
+// aprsLatitude formats a latitude as DDMM.mmN.
+func aprsLatitude(lat float64) string {
+	hemisphere := "N"
+	if lat < 0 {
+		hemisphere = "S"
+	}
+	deg, min := degreesMinutes(lat)
+	return fmt.Sprintf("%02d%05.2f%s", deg, min, hemisphere)
+}
+
+// aprsLongitude formats a longitude as DDDMM.mmW.
+func aprsLongitude(lon float64) string {
+	hemisphere := "E"
+	if lon < 0 {
+		hemisphere = "W"
+	}
+	deg, min := degreesMinutes(lon)
+	return fmt.Sprintf("%03d%05.2f%s", deg, min, hemisphere)
+}
+
+// degreesMinutes splits a coordinate into whole degrees and minutes rounded
+// to hundredths, carrying into the degrees when the minutes round up to 60.
+func degreesMinutes(coord float64) (int, float64) {
+	hundredths := int(math.Round(math.Abs(coord) * 60 * 100))
+	deg := hundredths / (60 * 100)
+	min := float64(hundredths%(60*100)) / 100
+	return deg, min
+}
@@ -0,0 +1,141 @@
+package sinks
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+	"wsrepeater/internal/weather"
+)
+
+func float(v float64) *float64 {
+	return &v
+}
+
+func TestCWOPPacket(t *testing.T) {
+	at := time.Date(2026, time.January, 2, 3, 4, 5, 0, time.UTC)
+	positioned := &CWOP{callsign: "EW1234", lat: 46.0878, lon: -64.7782, positioned: true}
+	positionless := &CWOP{callsign: "EW1234"}
+
+	tests := []struct {
+		name string
+		sink *CWOP
+		obs  *weather.Observation
+		want string
+	}{
+		{
+			name: "full report",
+			sink: positioned,
+			obs:  testObservation(t),
+			want: "EW1234>APRS,TCPIP*:@020304z4605.27N/06446.69W_180/005g009t050r010P025h80b10132L421ewsrepeater",
+		},
+		{
+			name: "negative temperature",
+			sink: positioned,
+			obs:  &weather.Observation{Time: at, TempF: float(-5)},
+			want: "EW1234>APRS,TCPIP*:@020304z4605.27N/06446.69W_.../...g...t-05ewsrepeater",
+		},
+		{
+			name: "saturated humidity",
+			sink: positioned,
+			obs:  &weather.Observation{Time: at, Humidity: float(100)},
+			want: "EW1234>APRS,TCPIP*:@020304z4605.27N/06446.69W_.../...g...t...h00ewsrepeater",
+		},
+		{
+			name: "humidity below one percent",
+			sink: positioned,
+			obs:  &weather.Observation{Time: at, Humidity: float(0.2)},
+			want: "EW1234>APRS,TCPIP*:@020304z4605.27N/06446.69W_.../...g...t...h01ewsrepeater",
+		},
+		{
+			name: "luminosity below 1000",
+			sink: positioned,
+			obs:  &weather.Observation{Time: at, SolarRadiation: float(999)},
+			want: "EW1234>APRS,TCPIP*:@020304z4605.27N/06446.69W_.../...g...t...L999ewsrepeater",
+		},
+		{
+			name: "luminosity of 1000 and above",
+			sink: positioned,
+			obs:  &weather.Observation{Time: at, SolarRadiation: float(1105)},
+			want: "EW1234>APRS,TCPIP*:@020304z4605.27N/06446.69W_.../...g...t...l105ewsrepeater",
+		},
+		{
+			name: "positionless",
+			sink: positionless,
+			obs:  &weather.Observation{Time: at, WindDir: float(90), WindSpeedMph: float(12), TempF: float(71.6)},
+			want: "EW1234>APRS,TCPIP*:_01020304c090s012g...t072ewsrepeater",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.sink.packet(tt.obs); got != tt.want {
+				t.Errorf("packet =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCWOPSend(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	lines := make(chan string, 2)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+
+		conn.Write([]byte("# aprsc 2.1.10\r\n"))
+		login, _ := reader.ReadString('\n')
+		lines <- login
+		conn.Write([]byte("# logresp EW1234 unverified, server T2TEST\r\n"))
+		packet, _ := reader.ReadString('\n')
+		lines <- packet
+	}()
+
+	s := &CWOP{server: listener.Addr().String(), callsign: "EW1234", passcode: "-1", timeout: 5 * time.Second}
+	obs := testObservation(t)
+	if err := s.Send(obs); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	if login := <-lines; login != "user EW1234 pass -1 vers wsrepeater 1.0\r\n" {
+		t.Errorf("login = %q", login)
+	}
+	if packet := <-lines; packet != s.packet(obs)+"\r\n" {
+		t.Errorf("packet = %q, want %q", packet, s.packet(obs)+"\r\n")
+	}
+}
+
+func TestCWOPSendRejectedLogin(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("# aprsc 2.1.10\r\n"))
+		bufio.NewReader(conn).ReadString('\n')
+		conn.Write([]byte("# server full\r\n"))
+	}()
+
+	s := &CWOP{server: listener.Addr().String(), callsign: "EW1234", passcode: "-1", timeout: 5 * time.Second}
+	err = s.Send(testObservation(t))
+	if err == nil || !strings.Contains(err.Error(), "unexpected login response") {
+		t.Fatalf("Send error = %v, want an unexpected login response", err)
+	}
+}
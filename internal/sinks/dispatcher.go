@@ -0,0 +1,371 @@
+package sinks
+
+import (
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"wsrepeater/internal/config"
+	"wsrepeater/internal/queue"
+	"wsrepeater/internal/weather"
+)
+
+// intakeSize is how many observations may wait in memory to be written to
+// the queue.
+const intakeSize = 100
+
+// Dispatcher fans every observation out to each enabled sink. Each sink has
+// its own persistent queue and worker, so a slow or failing service never
+// holds up the others, and uploads survive outages and restarts.
+//
+// Observations reach the queues through an in-memory intake that is not
+// persisted: the few waiting there when the process stops are lost, and
+// they are dropped if the intake is full.
+type Dispatcher struct {
+	queue   *queue.Queue
+	workers []*worker
+	intake  chan *weather.Observation
+
+	intakeDropped uint64
+}
+
+type worker struct {
+	sink        Sink
+	queue       *queue.Queue
+	wake        chan struct{}
+	minInterval time.Duration
+	capacity    int
+	overflow    queue.Overflow
+
+	mutex      sync.Mutex
+	lastQueued time.Time
+	status     UploadStatus
+
+	sent         uint64
+	failed       uint64
+	skipped      uint64
+	deadLettered uint64
+	dropped      uint64
+}
+
+// SinkStats counts the uploads of one sink.
+type SinkStats struct {
+	Name         string `json:"name"`
+	Sent         uint64 `json:"sent"`
+	Failed       uint64 `json:"failed"`
+	Skipped      uint64 `json:"skipped"`
+	DeadLettered uint64 `json:"deadLettered"`
+	Dropped      uint64 `json:"dropped"`
+	Overflow     string `json:"overflow"`
+	Queued       int    `json:"queued"`
+	DeadLetters  int    `json:"deadLetters"`
+
+	Status UploadStatus `json:"status"`
+}
+
+// NewDispatcher creates a dispatcher for sinks. Each sink's queue holds at
+// most capacity observations (zero means unbounded); what happens beyond that
+// is decided by overflow, or by <NAME>_OVERFLOW for a single sink.
+func NewDispatcher(sinks []Sink, q *queue.Queue, capacity int, overflow queue.Overflow) *Dispatcher {
+	d := &Dispatcher{
+		queue:  q,
+		intake: make(chan *weather.Observation, intakeSize),
+	}
+	for _, sink := range sinks {
+		d.workers = append(d.workers, &worker{
+			sink:        sink,
+			queue:       q,
+			wake:        make(chan struct{}, 1),
+			minInterval: uploadInterval(sink),
+			capacity:    capacity,
+			overflow:    overflowPolicy(sink, overflow),
+		})
+	}
+	return d
+}
+
+// Start runs one worker per sink, and the loop that queues incoming
+// observations for them.
+func (d *Dispatcher) Start() {
+	for _, w := range d.workers {
+		pending, _ := w.queue.Len(w.sink.Name())
+		log.Printf("Forwarding observations to %s (%d queued, %s on overflow)", w.sink.Name(), pending, w.overflow)
+		go w.run()
+	}
+	go d.run()
+}
+
+// Dispatch hands obs over for queueing and returns immediately, so the
+// gateway is never kept waiting on the disk or on a sink. If observations
+// arrive faster than they can be queued, obs is dropped.
+func (d *Dispatcher) Dispatch(obs *weather.Observation) {
+	select {
+	case d.intake <- obs:
+	default:
+		atomic.AddUint64(&d.intakeDropped, 1)
+		log.Printf("Ingest backlog full, dropping observation from %s", obs.DateUTC())
+	}
+}
+
+// IntakeDropped returns how many observations were dropped before reaching
+// any sink queue.
+func (d *Dispatcher) IntakeDropped() uint64 {
+	return atomic.LoadUint64(&d.intakeDropped)
+}
+
+func (d *Dispatcher) run() {
+	for obs := range d.intake {
+		for _, w := range d.workers {
+			w.enqueue(obs)
+		}
+	}
+}
+
+// enqueue adds obs to the sink's queue. Observations arriving sooner than the
+// sink's upload interval are decimated here, before they reach the queue.
+func (w *worker) enqueue(obs *weather.Observation) {
+	name := w.sink.Name()
+
+	if !w.due(obs) {
+		atomic.AddUint64(&w.skipped, 1)
+		return
+	}
+
+	dropped, err := w.queue.Push(name, obs, w.capacity, w.overflow)
+	if err != nil {
+		log.Printf("Error queueing observation for %s: %v", name, err)
+		return
+	}
+	if dropped > 0 {
+		atomic.AddUint64(&w.dropped, uint64(dropped))
+		// Coalescing routinely replaces the pending observation
+		if w.overflow != queue.Coalesce {
+			log.Printf("Queue for %s is full, dropped %d observation(s) (%s)", name, dropped, w.overflow)
+		}
+	}
+
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (w *worker) due(obs *weather.Observation) bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.minInterval > 0 && obs.Time.Sub(w.lastQueued) < w.minInterval {
+		return false
+	}
+	w.lastQueued = obs.Time
+	return true
+}
+
+func (w *worker) run() {
+	if b, ok := w.sink.(Batcher); ok {
+		w.runBatches(b)
+		return
+	}
+
+	name := w.sink.Name()
+
+	for {
+		entry, err := w.queue.Peek(name)
+		if err != nil {
+			log.Printf("Error reading queue for %s: %v", name, err)
+			time.Sleep(time.Minute)
+			continue
+		}
+		if entry == nil {
+			<-w.wake
+			continue
+		}
+
+		now := time.Now()
+		if w.queue.Expired(entry, now) {
+			w.deadLetter(entry)
+			continue
+		}
+		if wait := entry.NextAttempt.Sub(now); wait > 0 {
+			time.Sleep(wait)
+			continue
+		}
+
+		start := time.Now()
+		err = uploadError(w.sink.Send(entry.Observation))
+		w.mutex.Lock()
+		w.status.update(w.sink, err, time.Since(start))
+		w.mutex.Unlock()
+
+		if err != nil {
+			atomic.AddUint64(&w.failed, 1)
+			log.Printf("Error forwarding to %s (attempt %d): %v", name, entry.Attempts+1, err)
+			w.retry(entry, err)
+			continue
+		}
+
+		atomic.AddUint64(&w.sent, 1)
+		if err := w.queue.Ack(name, entry); err != nil {
+			log.Printf("Error removing uploaded observation from %s queue: %v", name, err)
+		}
+	}
+}
+
+// runBatches is the worker loop of sinks that upload in bulk. Observations
+// stay queued until the batch holding them has been uploaded, so a failed or
+// interrupted upload is retried with the same entries.
+func (w *worker) runBatches(b Batcher) {
+	name := w.sink.Name()
+	size := b.BatchSize()
+	if size < 1 {
+		size = 1
+	}
+
+	for {
+		entries, err := w.queue.PeekN(name, size)
+		if err != nil {
+			log.Printf("Error reading queue for %s: %v", name, err)
+			time.Sleep(time.Minute)
+			continue
+		}
+		if len(entries) == 0 {
+			<-w.wake
+			continue
+		}
+
+		now := time.Now()
+		oldest := entries[0]
+		if w.queue.Expired(oldest, now) {
+			w.deadLetter(oldest)
+			continue
+		}
+		if wait := oldest.NextAttempt.Sub(now); wait > 0 {
+			time.Sleep(wait)
+			continue
+		}
+
+		// Wait for a full batch, or for the oldest observation to be due
+		if len(entries) < size && oldest.Attempts == 0 {
+			if b.FlushInterval() <= 0 {
+				<-w.wake
+				continue
+			}
+			if wait := oldest.Enqueued.Add(b.FlushInterval()).Sub(now); wait > 0 {
+				select {
+				case <-w.wake:
+				case <-time.After(wait):
+				}
+				continue
+			}
+		}
+
+		observations := make([]*weather.Observation, len(entries))
+		for i, entry := range entries {
+			observations[i] = entry.Observation
+		}
+
+		start := time.Now()
+		err = uploadError(b.SendBatch(observations))
+		w.mutex.Lock()
+		w.status.update(w.sink, err, time.Since(start))
+		w.mutex.Unlock()
+
+		if err != nil {
+			atomic.AddUint64(&w.failed, uint64(len(entries)))
+			log.Printf("Error forwarding %d observation(s) to %s (attempt %d): %v", len(entries), name, oldest.Attempts+1, err)
+			for _, entry := range entries {
+				w.retry(entry, err)
+			}
+			continue
+		}
+
+		atomic.AddUint64(&w.sent, uint64(len(entries)))
+		if err := w.queue.Ack(name, entries...); err != nil {
+			log.Printf("Error removing uploaded observations from %s queue: %v", name, err)
+		}
+	}
+}
+
+// retry reschedules entry after a failed upload, or dead-letters it.
+func (w *worker) retry(entry *queue.Entry, uploadErr error) {
+	name := w.sink.Name()
+
+	dead, err := w.queue.Retry(name, entry, uploadErr, time.Now())
+	if err != nil {
+		log.Printf("Error rescheduling upload to %s: %v", name, err)
+		time.Sleep(time.Minute)
+	}
+	if dead {
+		atomic.AddUint64(&w.deadLettered, 1)
+		log.Printf("Gave up forwarding observation from %s to %s", entry.Observation.DateUTC(), name)
+	}
+}
+
+func (w *worker) deadLetter(entry *queue.Entry) {
+	atomic.AddUint64(&w.deadLettered, 1)
+	log.Printf("Observation from %s expired in %s queue", entry.Observation.DateUTC(), w.sink.Name())
+	if err := w.queue.DeadLetter(w.sink.Name(), entry); err != nil {
+		log.Printf("Error dead-lettering observation for %s: %v", w.sink.Name(), err)
+		time.Sleep(time.Minute)
+	}
+}
+
+// Stats returns the upload counters and queue depths of every sink.
+func (d *Dispatcher) Stats() []SinkStats {
+	stats := make([]SinkStats, 0, len(d.workers))
+	for _, w := range d.workers {
+		pending, dead := w.queue.Len(w.sink.Name())
+		w.mutex.Lock()
+		status := w.status
+		w.mutex.Unlock()
+
+		stats = append(stats, SinkStats{
+			Name:         w.sink.Name(),
+			Sent:         atomic.LoadUint64(&w.sent),
+			Failed:       atomic.LoadUint64(&w.failed),
+			Skipped:      atomic.LoadUint64(&w.skipped),
+			DeadLettered: atomic.LoadUint64(&w.deadLettered),
+			Dropped:      atomic.LoadUint64(&w.dropped),
+			Overflow:     w.overflow.String(),
+			Queued:       pending,
+			DeadLetters:  dead,
+			Status:       status,
+		})
+	}
+	return stats
+}
+
+// uploadInterval returns the minimum time between two uploads to sink, read
+// from <NAME>_INTERVAL (e.g. WINDY_INTERVAL=10m). It is never less than what
+// the service itself allows. Reports arriving sooner are decimated.
+func uploadInterval(sink Sink) time.Duration {
+	var min time.Duration
+	if t, ok := sink.(Throttled); ok {
+		min = t.MinInterval()
+	}
+
+	key := strings.ToUpper(sink.Name()) + "_INTERVAL"
+	interval := config.GetDuration(key, min)
+	if interval < min {
+		log.Printf("%s %v is below the %s minimum, using %v", key, interval, sink.Name(), min)
+		interval = min
+	}
+	return interval
+}
+
+// overflowPolicy returns the overflow policy of sink, read from
+// <NAME>_OVERFLOW (e.g. MQTT_OVERFLOW=coalesce), or def if unset.
+func overflowPolicy(sink Sink, def queue.Overflow) queue.Overflow {
+	key := strings.ToUpper(sink.Name()) + "_OVERFLOW"
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	overflow, err := queue.ParseOverflow(value)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", key, err)
+	}
+	return overflow
+}
@@ -0,0 +1,116 @@
+package sinks
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"wsrepeater/internal/queue"
+	"wsrepeater/internal/weather"
+)
+
+// batchSink fails its first uploads, recording every batch it was given.
+type batchSink struct {
+	mutex   sync.Mutex
+	fails   int
+	batches [][]time.Time
+}
+
+func (s *batchSink) Name() string { return "batch" }
+
+func (s *batchSink) Send(obs *weather.Observation) error {
+	return s.SendBatch([]*weather.Observation{obs})
+}
+
+func (s *batchSink) BatchSize() int               { return 2 }
+func (s *batchSink) FlushInterval() time.Duration { return time.Minute }
+
+func (s *batchSink) SendBatch(observations []*weather.Observation) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var batch []time.Time
+	for _, obs := range observations {
+		batch = append(batch, obs.Time)
+	}
+	s.batches = append(s.batches, batch)
+	if s.fails > 0 {
+		s.fails--
+		return errors.New("unavailable")
+	}
+	return nil
+}
+
+func TestDispatcherBatchRetry(t *testing.T) {
+	q, err := queue.Open(t.TempDir(), queue.Options{MinBackoff: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	sink := &batchSink{fails: 2}
+	d := NewDispatcher([]Sink{sink}, q, 0, queue.DropOldest)
+	d.Start()
+
+	start := time.Now()
+	d.Dispatch(&weather.Observation{Time: start})
+	d.Dispatch(&weather.Observation{Time: start.Add(time.Second)})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if stats := d.Stats()[0]; stats.Sent == 2 && stats.Queued == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("batch not uploaded: %+v", d.Stats()[0])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	if len(sink.batches) != 3 {
+		t.Fatalf("%d uploads, want 2 failures and a success", len(sink.batches))
+	}
+	for i, batch := range sink.batches {
+		if len(batch) != 2 {
+			t.Errorf("upload %d had %d observations, want the same 2 every time", i+1, len(batch))
+		}
+	}
+}
+
+func TestDispatcherRedactsCredentials(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	q, err := queue.Open(t.TempDir(), queue.Options{MinBackoff: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	sink := &PWSWeather{url: server.URL, id: "STATION", password: "hunter2", client: server.Client()}
+	d := NewDispatcher([]Sink{sink}, q, 0, queue.DropOldest)
+	d.Start()
+	d.Dispatch(testObservation(t))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for d.Stats()[0].Failed == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("upload to a closed server did not fail")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	status := d.Stats()[0].Status
+	if status.LastError == "" || strings.Contains(status.LastError, "hunter2") {
+		t.Errorf("LastError = %q, want the error without the password", status.LastError)
+	}
+	entry, _ := q.Peek(sink.Name())
+	if entry == nil || strings.Contains(entry.LastError, "hunter2") {
+		t.Errorf("queued error = %+v, want the error without the password", entry)
+	}
+}
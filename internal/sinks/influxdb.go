@@ -0,0 +1,199 @@
+package sinks
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"wsrepeater/internal/config"
+	"wsrepeater/internal/weather"
+)
+
+func init() {
+	Register("influxdb", newInfluxDB)
+}
+
+// InfluxDB writes observations as line protocol, to either the InfluxDB 1.x
+// /write endpoint or the 2.x /api/v2/write endpoint.
+type InfluxDB struct {
+	writeURL    string
+	token       string
+	username    string
+	password    string
+	measurement string
+	tags        map[string]string
+	client      *http.Client
+	batchSize   int
+	flushEvery  time.Duration
+
+	httpStatus
+}
+
+func newInfluxDB() (Sink, error) {
+	baseURL := strings.TrimSuffix(config.GetString("INFLUX_URL", "http://localhost:8086"), "/")
+	token := os.Getenv("INFLUX_TOKEN")
+
+	// Version 2 authenticates with a token, so default to it when one is set
+	defaultVersion := 1
+	if token != "" {
+		defaultVersion = 2
+	}
+
+	params := url.Values{}
+	params.Set("precision", "ns")
+
+	var writeURL string
+	switch config.GetInt("INFLUX_VERSION", defaultVersion) {
+	case 1:
+		database := os.Getenv("INFLUX_DATABASE")
+		if database == "" {
+			return nil, fmt.Errorf("INFLUX_DATABASE must be set")
+		}
+		params.Set("db", database)
+		if rp := os.Getenv("INFLUX_RETENTION_POLICY"); rp != "" {
+			params.Set("rp", rp)
+		}
+		writeURL = baseURL + "/write?" + params.Encode()
+	case 2:
+		org, bucket := os.Getenv("INFLUX_ORG"), os.Getenv("INFLUX_BUCKET")
+		if org == "" || bucket == "" || token == "" {
+			return nil, fmt.Errorf("INFLUX_ORG, INFLUX_BUCKET and INFLUX_TOKEN must be set")
+		}
+		params.Set("org", org)
+		params.Set("bucket", bucket)
+		writeURL = baseURL + "/api/v2/write?" + params.Encode()
+	default:
+		return nil, fmt.Errorf("INFLUX_VERSION must be 1 or 2")
+	}
+
+	s := &InfluxDB{
+		writeURL:    writeURL,
+		token:       token,
+		username:    os.Getenv("INFLUX_USERNAME"),
+		password:    os.Getenv("INFLUX_PASSWORD"),
+		measurement: config.GetString("INFLUX_MEASUREMENT", "weather"),
+		tags: map[string]string{
+			"station": config.GetString("INFLUX_STATION", os.Getenv("WUNDERGROUND_ID")),
+		},
+		client:     &http.Client{Timeout: 30 * time.Second},
+		batchSize:  config.GetInt("INFLUX_BATCH_SIZE", 10),
+		flushEvery: config.GetDuration("INFLUX_FLUSH_INTERVAL", time.Minute),
+	}
+
+	return s, nil
+}
+
+func (s *InfluxDB) Name() string {
+	return "influxdb"
+}
+
+func (s *InfluxDB) Send(obs *weather.Observation) error {
+	return s.SendBatch([]*weather.Observation{obs})
+}
+
+func (s *InfluxDB) BatchSize() int {
+	return s.batchSize
+}
+
+func (s *InfluxDB) FlushInterval() time.Duration {
+	return s.flushEvery
+}
+
+func (s *InfluxDB) SendBatch(observations []*weather.Observation) error {
+	var body bytes.Buffer
+	for _, obs := range observations {
+		if line := s.line(obs); line != "" {
+			body.WriteString(line)
+			body.WriteByte('\n')
+		}
+	}
+	if body.Len() == 0 {
+		return nil
+	}
+
+	req, err := http.NewRequest("POST", s.writeURL, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Token "+s.token)
+	} else if s.username != "" {
+		req.SetBasicAuth(s.username, s.password)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	s.record(resp)
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("received non-OK HTTP status: %v: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+
+	return nil
+}
+
+// line formats obs as one line of InfluxDB line protocol:
+//
+//	weather,station=ID tempf=51.3,humidity=80 1700000000000000000
+func (s *InfluxDB) line(obs *weather.Observation) string {
+	var fields []string
+	for _, key := range weather.ValueKeys() {
+		if v, ok := obs.Get(key); ok {
+			fields = append(fields, escapeInflux(key)+"="+weather.FormatFloat(v))
+		}
+	}
+	if len(fields) == 0 {
+		return ""
+	}
+
+	tags := make(map[string]string, len(s.tags)+2)
+	for k, v := range s.tags {
+		tags[k] = v
+	}
+	if obs.StationType != "" {
+		tags["stationtype"] = obs.StationType
+	}
+	if obs.Model != "" {
+		tags["model"] = obs.Model
+	}
+
+	keys := make([]string, 0, len(tags))
+	for k, v := range tags {
+		if v != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(escapeMeasurement(s.measurement))
+	for _, k := range keys {
+		b.WriteString("," + escapeInflux(k) + "=" + escapeInflux(tags[k]))
+	}
+	b.WriteString(" ")
+	b.WriteString(strings.Join(fields, ","))
+	fmt.Fprintf(&b, " %d", obs.Time.UnixNano())
+	return b.String()
+}
+
+// escapeInflux escapes the characters line protocol treats specially in tag
+// keys, tag values and field keys.
+func escapeInflux(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ",", `\,`, "=", `\=`, " ", `\ `).Replace(s)
+}
+
+// escapeMeasurement escapes a measurement name, in which an equals sign is
+// an ordinary character.
+func escapeMeasurement(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ",", `\,`, " ", `\ `).Replace(s)
+}
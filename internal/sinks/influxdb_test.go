@@ -0,0 +1,79 @@
+package sinks
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestInfluxDBBasicAuth(t *testing.T) {
+	var user, password, query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, _ = r.BasicAuth()
+		query = r.URL.RawQuery
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	s := &InfluxDB{
+		writeURL:    server.URL + "/write?db=weather&precision=ns",
+		username:    "writer",
+		password:    "hunter2",
+		measurement: "weather",
+		client:      server.Client(),
+	}
+	if err := s.Send(testObservation(t)); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if user != "writer" || password != "hunter2" {
+		t.Errorf("basic auth = %q, %q, want writer, hunter2", user, password)
+	}
+	if query != "db=weather&precision=ns" {
+		t.Errorf("query = %q, want no credentials", query)
+	}
+}
+
+func TestInfluxDBLine(t *testing.T) {
+	s := &InfluxDB{
+		measurement: "weather station",
+		tags:        map[string]string{"station": "back yard, north=1", "empty": ""},
+	}
+	obs := testObservation(t)
+	obs.Model = `GW1000\`
+
+	line := s.line(obs)
+	prefix := `weather\ station,model=GW1000\\,station=back\ yard\,\ north\=1 `
+	if !strings.HasPrefix(line, prefix) {
+		t.Errorf("line = %q, want it to start with %q", line, prefix)
+	}
+	if !strings.HasSuffix(line, " "+strconv.FormatInt(obs.Time.UnixNano(), 10)) {
+		t.Errorf("line = %q, want the timestamp in nanoseconds", line)
+	}
+	for _, field := range []string{"tempf=50", "humidity=80", "winddir=180"} {
+		if !strings.Contains(line, field) {
+			t.Errorf("line = %q, want field %s", line, field)
+		}
+	}
+}
+
+func TestEscapeInflux(t *testing.T) {
+	tests := []struct {
+		in, tag, measurement string
+	}{
+		{"plain", "plain", "plain"},
+		{"back yard", `back\ yard`, `back\ yard`},
+		{"a,b", `a\,b`, `a\,b`},
+		{"k=v", `k\=v`, "k=v"},
+		{`c:\`, `c:\\`, `c:\\`},
+	}
+	for _, tt := range tests {
+		if got := escapeInflux(tt.in); got != tt.tag {
+			t.Errorf("escapeInflux(%q) = %q, want %q", tt.in, got, tt.tag)
+		}
+		if got := escapeMeasurement(tt.in); got != tt.measurement {
+			t.Errorf("escapeMeasurement(%q) = %q, want %q", tt.in, got, tt.measurement)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package sinks
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+	"wsrepeater/internal/config"
+	"wsrepeater/internal/weather"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+const mqttPublishTimeout = 10 * time.Second
+
+func init() {
+	Register("mqtt", newMQTT)
+}
+
+// haSensor describes how one Ecowitt parameter appears in Home Assistant.
+type haSensor struct {
+	key         string
+	name        string
+	deviceClass string
+	unit        string
+	stateClass  string
+}
+
+// haSensors are announced through Home Assistant MQTT discovery. Values are
+// published in the units the gateway reports; Home Assistant converts them
+// to the user's unit system. The hourly total is a rolling 60-minute window
+// that falls without being reset, so unlike the other rain counters it is a
+// measurement rather than total_increasing.
+var haSensors = []haSensor{
+	{"tempf", "Temperature", "temperature", "°F", "measurement"},
+	{"humidity", "Humidity", "humidity", "%", "measurement"},
+	{"dewptf", "Dew point", "temperature", "°F", "measurement"},
+	{"feelslikef", "Feels like", "temperature", "°F", "measurement"},
+	{"tempinf", "Indoor temperature", "temperature", "°F", "measurement"},
+	{"humidityin", "Indoor humidity", "humidity", "%", "measurement"},
+	{"baromrelin", "Relative pressure", "atmospheric_pressure", "inHg", "measurement"},
+	{"baromabsin", "Absolute pressure", "atmospheric_pressure", "inHg", "measurement"},
+	{"winddir", "Wind direction", "", "°", "measurement"},
+	{"windspeedmph", "Wind speed", "wind_speed", "mph", "measurement"},
+	{"windgustmph", "Wind gust", "wind_speed", "mph", "measurement"},
+	{"maxdailygust", "Max daily gust", "wind_speed", "mph", "measurement"},
+	{"solarradiation", "Solar radiation", "irradiance", "W/m²", "measurement"},
+	{"uv", "UV index", "", "UV index", "measurement"},
+	{"rainratein", "Rain rate", "precipitation_intensity", "in/h", "measurement"},
+	{"eventrainin", "Event rain", "precipitation", "in", "total_increasing"},
+	{"hourlyrainin", "Hourly rain", "precipitation", "in", "measurement"},
+	{"dailyrainin", "Daily rain", "precipitation", "in", "total_increasing"},
+	{"weeklyrainin", "Weekly rain", "precipitation", "in", "total_increasing"},
+	{"monthlyrainin", "Monthly rain", "precipitation", "in", "total_increasing"},
+	{"yearlyrainin", "Yearly rain", "precipitation", "in", "total_increasing"},
+}
+
+// MQTT publishes every observation to an MQTT broker, as one JSON state
+// topic plus a topic per sensor, and announces the sensors to Home Assistant
+// with MQTT discovery.
+type MQTT struct {
+	client          mqtt.Client
+	topic           string
+	discoveryPrefix string
+	discovery       bool
+	nodeID          string
+}
+
+func newMQTT() (Sink, error) {
+	broker := os.Getenv("MQTT_BROKER")
+	if broker == "" {
+		return nil, fmt.Errorf("MQTT_BROKER must be set")
+	}
+
+	s := &MQTT{
+		topic:           config.GetString("MQTT_TOPIC", "wsrepeater"),
+		discoveryPrefix: config.GetString("MQTT_DISCOVERY_PREFIX", "homeassistant"),
+		discovery:       config.GetBool("MQTT_DISCOVERY", true),
+		nodeID:          config.GetString("MQTT_CLIENT_ID", "wsrepeater"),
+	}
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(broker).
+		SetClientID(s.nodeID).
+		SetUsername(os.Getenv("MQTT_USERNAME")).
+		SetPassword(os.Getenv("MQTT_PASSWORD")).
+		SetKeepAlive(60*time.Second).
+		// Reconnect with exponential backoff, both initially and after a drop
+		SetConnectRetry(true).
+		SetConnectRetryInterval(5*time.Second).
+		SetAutoReconnect(true).
+		SetMaxReconnectInterval(2*time.Minute).
+		SetWill(s.availabilityTopic(), "offline", 1, true).
+		SetOnConnectHandler(s.onConnect).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			log.Printf("MQTT connection lost: %v", err)
+		})
+
+	s.client = mqtt.NewClient(opts)
+	s.client.Connect()
+
+	return s, nil
+}
+
+func (s *MQTT) Name() string {
+	return "mqtt"
+}
+
+func (s *MQTT) availabilityTopic() string {
+	return s.topic + "/status"
+}
+
+func (s *MQTT) stateTopic() string {
+	return s.topic + "/state"
+}
+
+// onConnect runs after every (re)connection, so Home Assistant sees the
+// sensors again after either side restarts.
+func (s *MQTT) onConnect(client mqtt.Client) {
+	log.Printf("Connected to MQTT broker")
+
+	client.Publish(s.availabilityTopic(), 1, true, "online")
+
+	if !s.discovery {
+		return
+	}
+	for _, sensor := range haSensors {
+		payload, err := json.Marshal(s.discoveryConfig(sensor))
+		if err != nil {
+			log.Printf("Error encoding discovery config for %s: %v", sensor.key, err)
+			continue
+		}
+		topic := fmt.Sprintf("%s/sensor/%s/%s/config", s.discoveryPrefix, s.nodeID, sensor.key)
+		client.Publish(topic, 1, true, payload)
+	}
+}
+
+func (s *MQTT) discoveryConfig(sensor haSensor) map[string]interface{} {
+	cfg := map[string]interface{}{
+		"name":                sensor.name,
+		"unique_id":           s.nodeID + "_" + sensor.key,
+		"state_topic":         s.stateTopic(),
+		"value_template":      fmt.Sprintf("{{ value_json.%s }}", sensor.key),
+		"availability_topic":  s.availabilityTopic(),
+		"unit_of_measurement": sensor.unit,
+		"state_class":         sensor.stateClass,
+		"device": map[string]interface{}{
+			"identifiers":  []string{s.nodeID},
+			"name":         "Weather station",
+			"manufacturer": "Ecowitt",
+			"model":        "WS2320",
+			"sw_version":   "wsrepeater",
+		},
+	}
+	if sensor.deviceClass != "" {
+		cfg["device_class"] = sensor.deviceClass
+	}
+	return cfg
+}
+
+func (s *MQTT) Send(obs *weather.Observation) error {
+	if !s.client.IsConnectionOpen() {
+		return fmt.Errorf("not connected to broker")
+	}
+
+	state := make(map[string]interface{})
+	for _, key := range weather.ValueKeys() {
+		if v, ok := obs.Get(key); ok {
+			state[key] = v
+		}
+	}
+	state["dateutc"] = obs.DateUTC()
+
+	payload, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("error encoding state: %v", err)
+	}
+	if err := s.publish(s.stateTopic(), payload); err != nil {
+		return err
+	}
+
+	for _, key := range weather.ValueKeys() {
+		if v, ok := obs.Get(key); ok {
+			if err := s.publish(s.topic+"/"+key, weather.FormatFloat(v)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *MQTT) publish(topic string, payload interface{}) error {
+	token := s.client.Publish(topic, 0, false, payload)
+	if !token.WaitTimeout(mqttPublishTimeout) {
+		return fmt.Errorf("timed out publishing to %s", topic)
+	}
+	return token.Error()
+}
@@ -0,0 +1,155 @@
+package sinks
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// fakeClient records what is published instead of talking to a broker.
+type fakeClient struct {
+	mqtt.Client
+	published map[string]interface{}
+	retained  map[string]bool
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{published: make(map[string]interface{}), retained: make(map[string]bool)}
+}
+
+func (c *fakeClient) IsConnectionOpen() bool {
+	return true
+}
+
+func (c *fakeClient) Publish(topic string, qos byte, retained bool, payload interface{}) mqtt.Token {
+	c.published[topic] = payload
+	c.retained[topic] = retained
+	return doneToken{}
+}
+
+type doneToken struct {
+	mqtt.Token
+}
+
+func (doneToken) WaitTimeout(time.Duration) bool { return true }
+func (doneToken) Error() error                   { return nil }
+
+func testMQTT(client *fakeClient) *MQTT {
+	return &MQTT{
+		client:          client,
+		topic:           "weather",
+		discoveryPrefix: "homeassistant",
+		discovery:       true,
+		nodeID:          "station1",
+	}
+}
+
+func TestMQTTDiscovery(t *testing.T) {
+	client := newFakeClient()
+	testMQTT(client).onConnect(client)
+
+	if client.published["weather/status"] != "online" || !client.retained["weather/status"] {
+		t.Errorf("availability = %v (retained %v), want retained online", client.published["weather/status"], client.retained["weather/status"])
+	}
+
+	for _, sensor := range haSensors {
+		topic := fmt.Sprintf("homeassistant/sensor/station1/%s/config", sensor.key)
+		payload, ok := client.published[topic].([]byte)
+		if !ok {
+			t.Errorf("no discovery config published for %s", sensor.key)
+			continue
+		}
+		if !client.retained[topic] {
+			t.Errorf("discovery config of %s is not retained", sensor.key)
+		}
+
+		var cfg map[string]interface{}
+		if err := json.Unmarshal(payload, &cfg); err != nil {
+			t.Fatalf("discovery config of %s: %v", sensor.key, err)
+		}
+		want := map[string]interface{}{
+			"unique_id":           "station1_" + sensor.key,
+			"state_topic":         "weather/state",
+			"availability_topic":  "weather/status",
+			"value_template":      "{{ value_json." + sensor.key + " }}",
+			"unit_of_measurement": sensor.unit,
+			"state_class":         sensor.stateClass,
+		}
+		for key, value := range want {
+			if cfg[key] != value {
+				t.Errorf("%s %s = %v, want %v", sensor.key, key, cfg[key], value)
+			}
+		}
+		if _, ok := cfg["device_class"]; ok != (sensor.deviceClass != "") {
+			t.Errorf("%s device_class = %v, want %q", sensor.key, cfg["device_class"], sensor.deviceClass)
+		}
+	}
+}
+
+func TestMQTTRainStateClass(t *testing.T) {
+	want := map[string]string{
+		"rainratein":    "measurement",
+		"hourlyrainin":  "measurement",
+		"eventrainin":   "total_increasing",
+		"dailyrainin":   "total_increasing",
+		"weeklyrainin":  "total_increasing",
+		"monthlyrainin": "total_increasing",
+		"yearlyrainin":  "total_increasing",
+	}
+	for _, sensor := range haSensors {
+		if class, ok := want[sensor.key]; ok && sensor.stateClass != class {
+			t.Errorf("%s state class = %q, want %q", sensor.key, sensor.stateClass, class)
+		}
+	}
+}
+
+func TestMQTTDiscoveryDisabled(t *testing.T) {
+	client := newFakeClient()
+	s := testMQTT(client)
+	s.discovery = false
+	s.onConnect(client)
+
+	if len(client.published) != 1 {
+		t.Errorf("published %d topics, want only the availability topic", len(client.published))
+	}
+}
+
+func TestMQTTSend(t *testing.T) {
+	client := newFakeClient()
+	if err := testMQTT(client).Send(testObservation(t)); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	payload, ok := client.published["weather/state"].([]byte)
+	if !ok {
+		t.Fatal("no state published")
+	}
+	var state map[string]interface{}
+	if err := json.Unmarshal(payload, &state); err != nil {
+		t.Fatalf("state: %v", err)
+	}
+	want := map[string]interface{}{
+		"tempf":    50.0,
+		"humidity": 80.0,
+		"winddir":  180.0,
+		"dateutc":  "2026-01-02 03:04:05",
+	}
+	for key, value := range want {
+		if state[key] != value {
+			t.Errorf("state %s = %v, want %v", key, state[key], value)
+		}
+	}
+	if _, ok := state["dewptf"]; !ok {
+		t.Error("state lacks the derived dew point")
+	}
+
+	if got := client.published["weather/tempf"]; got != "50" {
+		t.Errorf("weather/tempf = %v, want 50", got)
+	}
+	if client.retained["weather/state"] {
+		t.Error("state is retained")
+	}
+}
@@ -0,0 +1,180 @@
+package sinks
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"wsrepeater/internal/config"
+	"wsrepeater/internal/weather"
+
+	"github.com/golang/snappy"
+)
+
+func init() {
+	Register("prometheus", newPrometheus)
+}
+
+// Prometheus pushes observations with the Prometheus remote-write protocol,
+// as accepted by Prometheus itself, Mimir, VictoriaMetrics and others. Each
+// Ecowitt parameter becomes a series named <prefix><key>.
+type Prometheus struct {
+	url         string
+	username    string
+	password    string
+	bearerToken string
+	prefix      string
+	station     string
+	client      *http.Client
+	batchSize   int
+	flushEvery  time.Duration
+
+	httpStatus
+}
+
+func newPrometheus() (Sink, error) {
+	writeURL := os.Getenv("PROM_REMOTE_WRITE_URL")
+	if writeURL == "" {
+		return nil, fmt.Errorf("PROM_REMOTE_WRITE_URL must be set")
+	}
+
+	s := &Prometheus{
+		url:         writeURL,
+		username:    os.Getenv("PROM_USERNAME"),
+		password:    os.Getenv("PROM_PASSWORD"),
+		bearerToken: os.Getenv("PROM_BEARER_TOKEN"),
+		prefix:      config.GetString("PROM_METRIC_PREFIX", "weather_"),
+		station:     config.GetString("PROM_STATION", os.Getenv("WUNDERGROUND_ID")),
+		client:      &http.Client{Timeout: 30 * time.Second},
+		batchSize:   config.GetInt("PROM_BATCH_SIZE", 10),
+		flushEvery:  config.GetDuration("PROM_FLUSH_INTERVAL", time.Minute),
+	}
+
+	return s, nil
+}
+
+func (s *Prometheus) Name() string {
+	return "prometheus"
+}
+
+func (s *Prometheus) Send(obs *weather.Observation) error {
+	return s.SendBatch([]*weather.Observation{obs})
+}
+
+func (s *Prometheus) BatchSize() int {
+	return s.batchSize
+}
+
+func (s *Prometheus) FlushInterval() time.Duration {
+	return s.flushEvery
+}
+
+type promSample struct {
+	value     float64
+	timestamp int64
+}
+
+func (s *Prometheus) SendBatch(observations []*weather.Observation) error {
+	series := make(map[string][]promSample)
+	for _, obs := range observations {
+		for _, key := range weather.ValueKeys() {
+			if v, ok := obs.Get(key); ok {
+				name := s.prefix + key
+				series[name] = append(series[name], promSample{v, obs.Time.UnixNano() / int64(time.Millisecond)})
+			}
+		}
+	}
+	if len(series) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(series))
+	for name := range series {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var writeRequest bytes.Buffer
+	for _, name := range names {
+		labels := [][2]string{{"__name__", name}}
+		if s.station != "" {
+			labels = append(labels, [2]string{"station", s.station})
+		}
+		protoBytes(&writeRequest, 1, encodeTimeSeries(labels, series[name]))
+	}
+
+	req, err := http.NewRequest("POST", s.url, bytes.NewReader(snappy.Encode(nil, writeRequest.Bytes())))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", "wsrepeater")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	if s.bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+s.bearerToken)
+	} else if s.username != "" {
+		req.SetBasicAuth(s.username, s.password)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	s.record(resp)
+
+	if resp.StatusCode/100 != 2 {
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("received non-OK HTTP status: %v: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+
+	return nil
+}
+
+// The remote-write payload is a small protobuf message, encoded by hand:
+//
+//	message WriteRequest { repeated TimeSeries timeseries = 1; }
+//	message TimeSeries   { repeated Label labels = 1; repeated Sample samples = 2; }
+//	message Label        { string name = 1; string value = 2; }
+//	message Sample       { double value = 1; int64 timestamp = 2; }
+
+func encodeTimeSeries(labels [][2]string, samples []promSample) []byte {
+	var ts bytes.Buffer
+	for _, label := range labels {
+		var l bytes.Buffer
+		protoBytes(&l, 1, []byte(label[0]))
+		protoBytes(&l, 2, []byte(label[1]))
+		protoBytes(&ts, 1, l.Bytes())
+	}
+	for _, sample := range samples {
+		var s bytes.Buffer
+		protoVarint(&s, 1<<3|1) // field 1, 64-bit
+		var bits [8]byte
+		binary.LittleEndian.PutUint64(bits[:], math.Float64bits(sample.value))
+		s.Write(bits[:])
+		protoVarint(&s, 2<<3|0) // field 2, varint
+		protoVarint(&s, uint64(sample.timestamp))
+		protoBytes(&ts, 2, s.Bytes())
+	}
+	return ts.Bytes()
+}
+
+// protoBytes writes a length-delimited field.
+func protoBytes(buf *bytes.Buffer, field int, data []byte) {
+	protoVarint(buf, uint64(field)<<3|2)
+	protoVarint(buf, uint64(len(data)))
+	buf.Write(data)
+}
+
+func protoVarint(buf *bytes.Buffer, v uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	buf.Write(tmp[:n])
+}
@@ -0,0 +1,157 @@
+package sinks
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"wsrepeater/internal/weather"
+
+	"github.com/golang/snappy"
+)
+
+// protoField is one field of a decoded protobuf message.
+type protoField struct {
+	number int
+	varint uint64
+	bytes  []byte
+}
+
+// decodeProto splits a protobuf message into its fields, supporting the
+// wire types the remote-write payload uses.
+func decodeProto(t *testing.T, data []byte) []protoField {
+	t.Helper()
+
+	var fields []protoField
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			t.Fatalf("invalid field key in %x", data)
+		}
+		data = data[n:]
+
+		f := protoField{number: int(key >> 3)}
+		switch key & 7 {
+		case 0:
+			f.varint, n = binary.Uvarint(data)
+			if n <= 0 {
+				t.Fatalf("invalid varint in %x", data)
+			}
+			data = data[n:]
+		case 1:
+			if len(data) < 8 {
+				t.Fatalf("truncated 64-bit field in %x", data)
+			}
+			f.varint, data = binary.LittleEndian.Uint64(data), data[8:]
+		case 2:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < length {
+				t.Fatalf("invalid length in %x", data)
+			}
+			f.bytes, data = data[n:n+int(length)], data[n+int(length):]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+type decodedSeries struct {
+	labels  map[string]string
+	samples []promSample
+}
+
+// decodeWriteRequest decodes a remote-write WriteRequest into its series.
+func decodeWriteRequest(t *testing.T, data []byte) map[string]decodedSeries {
+	t.Helper()
+
+	series := make(map[string]decodedSeries)
+	for _, ts := range decodeProto(t, data) {
+		if ts.number != 1 {
+			t.Fatalf("WriteRequest has field %d, want only timeseries", ts.number)
+		}
+		s := decodedSeries{labels: make(map[string]string)}
+		for _, f := range decodeProto(t, ts.bytes) {
+			switch f.number {
+			case 1:
+				label := decodeProto(t, f.bytes)
+				s.labels[string(label[0].bytes)] = string(label[1].bytes)
+			case 2:
+				var sample promSample
+				for _, sf := range decodeProto(t, f.bytes) {
+					switch sf.number {
+					case 1:
+						sample.value = math.Float64frombits(sf.varint)
+					case 2:
+						sample.timestamp = int64(sf.varint)
+					}
+				}
+				s.samples = append(s.samples, sample)
+			}
+		}
+		series[s.labels["__name__"]] = s
+	}
+	return series
+}
+
+func TestPrometheusSendBatch(t *testing.T) {
+	var body []byte
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		body, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	s := &Prometheus{url: server.URL, prefix: "weather_", station: "KXX123", bearerToken: "token", client: server.Client()}
+	first := testObservation(t)
+	second := testObservation(t)
+	second.Time = first.Time.Add(time.Minute)
+	warmer := 51.5
+	second.TempF = &warmer
+
+	if err := s.SendBatch([]*weather.Observation{first, second}); err != nil {
+		t.Fatalf("SendBatch: %v", err)
+	}
+
+	if header.Get("Content-Encoding") != "snappy" || header.Get("Content-Type") != "application/x-protobuf" {
+		t.Errorf("headers = %v, want a snappy-compressed protobuf", header)
+	}
+	if header.Get("Authorization") != "Bearer token" {
+		t.Errorf("Authorization = %q", header.Get("Authorization"))
+	}
+
+	decoded, err := snappy.Decode(nil, body)
+	if err != nil {
+		t.Fatalf("body is not snappy-compressed: %v", err)
+	}
+	series := decodeWriteRequest(t, decoded)
+
+	temp, ok := series["weather_tempf"]
+	if !ok {
+		t.Fatalf("no weather_tempf series among %d", len(series))
+	}
+	if temp.labels["station"] != "KXX123" {
+		t.Errorf("station label = %q", temp.labels["station"])
+	}
+	want := []promSample{
+		{50, first.Time.UnixNano() / int64(time.Millisecond)},
+		{51.5, second.Time.UnixNano() / int64(time.Millisecond)},
+	}
+	if len(temp.samples) != len(want) {
+		t.Fatalf("weather_tempf has %d samples, want %d", len(temp.samples), len(want))
+	}
+	for i := range want {
+		if temp.samples[i] != want[i] {
+			t.Errorf("sample %d = %+v, want %+v", i, temp.samples[i], want[i])
+		}
+	}
+	if humidity := series["weather_humidity"]; len(humidity.samples) != 2 || humidity.samples[0].value != 80 {
+		t.Errorf("weather_humidity samples = %+v", humidity.samples)
+	}
+}
@@ -0,0 +1,80 @@
+package sinks
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+	"wsrepeater/internal/config"
+	"wsrepeater/internal/weather"
+)
+
+const pwsWeatherURL = "https://pwsupdate.pwsweather.com/api/v1/submitwx"
+
+func init() {
+	Register("pwsweather", newPWSWeather)
+}
+
+// PWSWeather uploads observations to PWSWeather (AerisWeather), which
+// accepts the Wunderground updateweatherstation parameters.
+type PWSWeather struct {
+	url      string
+	id       string
+	password string
+	software string
+	client   *http.Client
+
+	httpStatus
+}
+
+func newPWSWeather() (Sink, error) {
+	id := os.Getenv("PWSWEATHER_ID")
+	password := os.Getenv("PWSWEATHER_PASS")
+	if id == "" || password == "" {
+		return nil, fmt.Errorf("PWSWEATHER_ID and PWSWEATHER_PASS must be set")
+	}
+
+	return &PWSWeather{
+		url:      config.GetString("PWSWEATHER_URL", pwsWeatherURL),
+		id:       id,
+		password: password,
+		software: os.Getenv("STATION_SOFTWARE"),
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func (s *PWSWeather) Name() string {
+	return "pwsweather"
+}
+
+func (s *PWSWeather) Send(obs *weather.Observation) error {
+	data := wundergroundValues(obs)
+	data.Set("ID", s.id)
+	data.Set("PASSWORD", s.password)
+	data.Set("softwaretype", s.software)
+	data.Set("action", "updateraw")
+
+	resp, err := s.client.Get(s.url + "?" + data.Encode())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	s.record(resp)
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading response: %v", err)
+	}
+
+	body := strings.TrimSpace(string(respBody))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("received non-OK HTTP status: %v: %s", resp.Status, body)
+	}
+	if strings.Contains(strings.ToLower(body), "error") {
+		return fmt.Errorf("unexpected response: %s", body)
+	}
+
+	return nil
+}
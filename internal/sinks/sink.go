@@ -0,0 +1,91 @@
+package sinks
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"wsrepeater/internal/config"
+	"wsrepeater/internal/weather"
+)
+
+// Sink is a service that observations are forwarded to.
+type Sink interface {
+	// Name identifies the sink in logs and configuration.
+	Name() string
+	// Send uploads one observation. It is only ever called from the sink's
+	// own worker, so implementations need not be safe for concurrent use.
+	Send(obs *weather.Observation) error
+}
+
+// Throttled is implemented by sinks whose service accepts at most one upload
+// per MinInterval. Observations arriving sooner are skipped for that sink.
+type Throttled interface {
+	MinInterval() time.Duration
+}
+
+// Batcher is implemented by sinks that upload in bulk. Their worker takes up
+// to BatchSize observations off the queue at once and only acknowledges them
+// once SendBatch succeeds. A partial batch is sent when its oldest
+// observation has waited FlushInterval; zero waits for a full batch.
+type Batcher interface {
+	SendBatch(observations []*weather.Observation) error
+	BatchSize() int
+	FlushInterval() time.Duration
+}
+
+// Factory builds a sink from its environment configuration.
+type Factory func() (Sink, error)
+
+var registry = make(map[string]Factory)
+
+// Register makes a sink available under name. It is called from the init
+// function of each sink implementation.
+func Register(name string, factory Factory) {
+	if _, exists := registry[name]; exists {
+		panic(fmt.Sprintf("sink %s registered twice", name))
+	}
+	registry[name] = factory
+}
+
+// Enabled builds the sinks listed in the comma-separated SINKS environment
+// variable. Only Wunderground is enabled when it is not set, and none at all
+// when it is "none", to only archive observations and serve the dashboard.
+func Enabled() ([]Sink, error) {
+	var sinks []Sink
+
+	list := config.GetString("SINKS", "wunderground")
+	if strings.EqualFold(strings.TrimSpace(list), "none") {
+		return sinks, nil
+	}
+
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(strings.ToLower(name))
+		if name == "" {
+			continue
+		}
+
+		factory, ok := registry[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown sink %q, available sinks: %s", name, strings.Join(Available(), ", "))
+		}
+
+		sink, err := factory()
+		if err != nil {
+			return nil, fmt.Errorf("error configuring sink %s: %v", name, err)
+		}
+		sinks = append(sinks, sink)
+	}
+
+	return sinks, nil
+}
+
+// Available returns the names of all registered sinks.
+func Available() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,62 @@
+package sinks
+
+import (
+	"net/url"
+	"testing"
+	"wsrepeater/internal/weather"
+)
+
+// testObservation returns a complete outdoor report as the gateway sends it.
+func testObservation(t *testing.T) *weather.Observation {
+	t.Helper()
+
+	values := url.Values{}
+	values.Set("dateutc", "2026-01-02 03:04:05")
+	values.Set("tempf", "50")
+	values.Set("humidity", "80")
+	values.Set("baromrelin", "29.92")
+	values.Set("baromabsin", "29.80")
+	values.Set("windspeedmph", "4.5")
+	values.Set("windgustmph", "9")
+	values.Set("winddir", "180")
+	values.Set("hourlyrainin", "0.1")
+	values.Set("dailyrainin", "0.25")
+	values.Set("solarradiation", "420.5")
+	values.Set("uv", "3")
+
+	obs := weather.ParseEcowitt(values)
+	if len(obs.Invalid) > 0 {
+		t.Fatalf("invalid test observation fields: %v", obs.Invalid)
+	}
+	return obs
+}
+
+func TestEnabled(t *testing.T) {
+	tests := []struct {
+		sinks   string
+		want    int
+		wantErr bool
+	}{
+		{"none", 0, false},
+		{" None ", 0, false},
+		{"wunderground", 1, false},
+		{"wunderground, windy", 0, true},
+		{"nonexistent", 0, true},
+	}
+
+	t.Setenv("WUNDERGROUND_ID", "KXX123")
+	t.Setenv("WUNDERGROUND_PASS", "secret")
+	t.Setenv("WINDY_API_KEY", "")
+	for _, tt := range tests {
+		t.Run(tt.sinks, func(t *testing.T) {
+			t.Setenv("SINKS", tt.sinks)
+			sinks, err := Enabled()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Enabled error = %v, want error %v", err, tt.wantErr)
+			}
+			if len(sinks) != tt.want {
+				t.Errorf("Enabled returned %d sinks, want %d", len(sinks), tt.want)
+			}
+		})
+	}
+}
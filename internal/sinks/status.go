@@ -0,0 +1,69 @@
+package sinks
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+)
+
+// UploadStatus describes the outcome of the latest uploads to a sink.
+type UploadStatus struct {
+	LastSuccess         *time.Time `json:"lastSuccess"`
+	LastFailure         *time.Time `json:"lastFailure"`
+	LastError           string     `json:"lastError,omitempty"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	HTTPStatus          int        `json:"httpStatus,omitempty"`
+	LatencyMs           float64    `json:"latencyMs"`
+}
+
+// StatusReporter is implemented by sinks that upload over HTTP, to report
+// the status code of their latest response.
+type StatusReporter interface {
+	LastStatusCode() int
+}
+
+// httpStatus remembers the status code of a sink's latest HTTP response.
+// HTTP sinks embed it to implement StatusReporter.
+type httpStatus struct {
+	code int32
+}
+
+func (h *httpStatus) record(resp *http.Response) {
+	atomic.StoreInt32(&h.code, int32(resp.StatusCode))
+}
+
+func (h *httpStatus) LastStatusCode() int {
+	return int(atomic.LoadInt32(&h.code))
+}
+
+// update records the outcome of one upload attempt that took latency.
+func (s *UploadStatus) update(sink Sink, err error, latency time.Duration) {
+	now := time.Now()
+	s.LatencyMs = float64(latency) / float64(time.Millisecond)
+	if r, ok := sink.(StatusReporter); ok {
+		s.HTTPStatus = r.LastStatusCode()
+	}
+
+	if err != nil {
+		s.LastFailure = &now
+		s.LastError = err.Error()
+		s.ConsecutiveFailures++
+		return
+	}
+	s.LastSuccess = &now
+	s.ConsecutiveFailures = 0
+}
+
+// uploadError strips the request URL from the transport error of an upload.
+// Several services take their credentials in the URL, and upload errors are
+// logged, stored in the queue and served on /stats/sinks.
+func uploadError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return fmt.Errorf("%s: %w", urlErr.Op, urlErr.Err)
+	}
+	return err
+}
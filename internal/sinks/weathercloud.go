@@ -0,0 +1,136 @@
+package sinks
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"wsrepeater/internal/config"
+	"wsrepeater/internal/weather"
+)
+
+const weathercloudURL = "http://api.weathercloud.net/v01/set"
+
+// Weathercloud accepts one upload every 10 minutes on regular accounts
+const weathercloudMinInterval = 10 * time.Minute
+
+func init() {
+	Register("weathercloud", newWeathercloud)
+}
+
+// Weathercloud uploads observations to Weathercloud. Its API takes the
+// values as path segments, encoded as integers in tenths of metric units.
+type Weathercloud struct {
+	url    string
+	wid    string
+	key    string
+	client *http.Client
+
+	httpStatus
+}
+
+func newWeathercloud() (Sink, error) {
+	wid := os.Getenv("WEATHERCLOUD_ID")
+	key := os.Getenv("WEATHERCLOUD_KEY")
+	if wid == "" || key == "" {
+		return nil, fmt.Errorf("WEATHERCLOUD_ID and WEATHERCLOUD_KEY must be set")
+	}
+
+	return &Weathercloud{
+		url:    config.GetString("WEATHERCLOUD_URL", weathercloudURL),
+		wid:    wid,
+		key:    key,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func (s *Weathercloud) Name() string {
+	return "weathercloud"
+}
+
+func (s *Weathercloud) MinInterval() time.Duration {
+	return weathercloudMinInterval
+}
+
+func (s *Weathercloud) Send(obs *weather.Observation) error {
+	m := obs.Metric()
+
+	var b strings.Builder
+	b.WriteString(strings.TrimSuffix(s.url, "/"))
+	add := func(name, value string) {
+		b.WriteString("/" + name + "/" + value)
+	}
+	addTenths := func(name string, p *float64) {
+		if p != nil {
+			add(name, strconv.Itoa(int(math.Round(*p*10))))
+		}
+	}
+
+	add("wid", s.wid)
+	add("key", s.key)
+	addTenths("temp", m.TempC)
+	addTenths("tempin", m.IndoorTempC)
+	if d := obs.Derived(); d.DewPointF != nil {
+		dewPoint := weather.FahrenheitToCelsius(*d.DewPointF)
+		addTenths("dew", &dewPoint)
+	}
+	if m.Humidity != nil {
+		add("hum", strconv.Itoa(int(math.Round(*m.Humidity))))
+	}
+	if m.IndoorHumidity != nil {
+		add("humin", strconv.Itoa(int(math.Round(*m.IndoorHumidity))))
+	}
+	if obs.WindSpeedMph != nil {
+		speed := weather.MphToMs(*obs.WindSpeedMph)
+		addTenths("wspd", &speed)
+	}
+	if obs.WindGustMph != nil {
+		gust := weather.MphToMs(*obs.WindGustMph)
+		addTenths("wspdhi", &gust)
+	}
+	if obs.WindDir != nil {
+		add("wdir", strconv.Itoa(int(math.Round(*obs.WindDir))))
+	}
+	addTenths("bar", m.PressureHPa)
+	addTenths("rain", m.DailyRainMm)
+	addTenths("rainrate", m.RainRateMm)
+	addTenths("solarrad", obs.SolarRadiation)
+	addTenths("uvi", obs.UV)
+	add("date", obs.Time.UTC().Format("20060102"))
+	add("time", obs.Time.UTC().Format("1504"))
+	add("software", "wsrepeater")
+
+	resp, err := s.client.Get(b.String())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	s.record(resp)
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading response: %v", err)
+	}
+
+	// Weathercloud answers 200 with its own status code as the body
+	body := strings.TrimSpace(string(respBody))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("received non-OK HTTP status: %v: %s", resp.Status, body)
+	}
+	switch body {
+	case "200":
+		return nil
+	case "400":
+		return fmt.Errorf("bad request")
+	case "401":
+		return fmt.Errorf("wrong station ID or key")
+	case "429":
+		return fmt.Errorf("uploads too frequent")
+	default:
+		return fmt.Errorf("unexpected response: %s", body)
+	}
+}
@@ -0,0 +1,68 @@
+package sinks
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWeathercloudSend(t *testing.T) {
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		fmt.Fprint(w, "200")
+	}))
+	defer server.Close()
+
+	s := &Weathercloud{url: server.URL + "/v01/set", wid: "abc", key: "def", client: server.Client()}
+	if err := s.Send(testObservation(t)); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	for _, segment := range []string{
+		"/v01/set/wid/abc/key/def/",
+		"/temp/100/",
+		"/hum/80/",
+		"/wspd/20/",
+		"/wspdhi/40/",
+		"/wdir/180/",
+		"/bar/10132/",
+		"/rain/64/",
+		"/solarrad/4205/",
+		"/uvi/30/",
+		"/date/20260102/time/0304/",
+	} {
+		if !strings.Contains(path, segment) {
+			t.Errorf("path %s lacks %s", path, segment)
+		}
+	}
+}
+
+func TestWeathercloudSendStatus(t *testing.T) {
+	tests := []struct {
+		body    string
+		wantErr bool
+	}{
+		{"200", false},
+		{"400", true},
+		{"401", true},
+		{"429", true},
+		{"unexpected", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.body, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintln(w, tt.body)
+			}))
+			defer server.Close()
+
+			s := &Weathercloud{url: server.URL, client: server.Client()}
+			if err := s.Send(testObservation(t)); (err != nil) != tt.wantErr {
+				t.Errorf("Send error = %v, want error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package sinks
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+	"wsrepeater/internal/config"
+	"wsrepeater/internal/weather"
+)
+
+const windyURL = "https://stations.windy.com/pws/update/"
+
+// Windy rejects updates from the same station more often than every 5 minutes
+const windyMinInterval = 5 * time.Minute
+
+func init() {
+	Register("windy", newWindy)
+}
+
+// Windy uploads observations to Windy.com with the PWS upload API, using its
+// metric parameter set.
+type Windy struct {
+	url     string
+	apiKey  string
+	station string
+	client  *http.Client
+
+	httpStatus
+}
+
+func newWindy() (Sink, error) {
+	apiKey := os.Getenv("WINDY_API_KEY")
+	if apiKey == "" {
+		return nil, fmt.Errorf("WINDY_API_KEY must be set")
+	}
+
+	return &Windy{
+		url:     config.GetString("WINDY_URL", windyURL),
+		apiKey:  apiKey,
+		station: config.GetString("WINDY_STATION_ID", "0"),
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func (s *Windy) Name() string {
+	return "windy"
+}
+
+func (s *Windy) MinInterval() time.Duration {
+	return windyMinInterval
+}
+
+func (s *Windy) Send(obs *weather.Observation) error {
+	m := obs.Metric()
+
+	data := url.Values{}
+	data.Set("station", s.station)
+	data.Set("ts", fmt.Sprintf("%d", obs.Time.Unix()))
+	setFixed(data, "temp", m.TempC, 1)
+	setFixed(data, "rh", m.Humidity, 0)
+	if d := obs.Derived(); d.DewPointF != nil {
+		dewPoint := weather.FahrenheitToCelsius(*d.DewPointF)
+		setFixed(data, "dewpoint", &dewPoint, 1)
+	}
+	if obs.BaromRelIn != nil {
+		pascals := weather.InHgToHPa(*obs.BaromRelIn) * 100
+		setFixed(data, "pressure", &pascals, 0)
+	}
+	if obs.WindSpeedMph != nil {
+		speed := weather.MphToMs(*obs.WindSpeedMph)
+		setFixed(data, "wind", &speed, 1)
+	}
+	if obs.WindGustMph != nil {
+		gust := weather.MphToMs(*obs.WindGustMph)
+		setFixed(data, "gust", &gust, 1)
+	}
+	setFixed(data, "winddir", obs.WindDir, 0)
+	setFixed(data, "precip", m.HourlyRainMm, 1)
+	setFixed(data, "uv", obs.UV, 0)
+	setFixed(data, "solarradiation", obs.SolarRadiation, 1)
+
+	resp, err := s.client.Get(strings.TrimSuffix(s.url, "/") + "/" + url.PathEscape(s.apiKey) + "?" + data.Encode())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	s.record(resp)
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading response: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("received non-OK HTTP status: %v: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package sinks
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestWindySend(t *testing.T) {
+	var path string
+	var query url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, query = r.URL.Path, r.URL.Query()
+	}))
+	defer server.Close()
+
+	s := &Windy{url: server.URL + "/pws/update/", apiKey: "secret", station: "2", client: server.Client()}
+	obs := testObservation(t)
+	if err := s.Send(obs); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	if path != "/pws/update/secret" {
+		t.Errorf("path = %q, want the API key appended", path)
+	}
+	want := map[string]string{
+		"station":  "2",
+		"ts":       strconv.FormatInt(obs.Time.Unix(), 10),
+		"temp":     "10.0",
+		"rh":       "80",
+		"pressure": "101321",
+		"wind":     "2.0",
+		"gust":     "4.0",
+		"winddir":  "180",
+		"precip":   "2.5",
+		"uv":       "3",
+	}
+	for key, value := range want {
+		if got := query.Get(key); got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+	if s.LastStatusCode() != http.StatusOK {
+		t.Errorf("LastStatusCode = %d, want 200", s.LastStatusCode())
+	}
+}
+
+func TestWindySendError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid API key", http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	s := &Windy{url: server.URL, apiKey: "wrong", client: &http.Client{Timeout: time.Second}}
+	if err := s.Send(testObservation(t)); err == nil {
+		t.Fatal("Send succeeded on a 401 response")
+	}
+	if s.LastStatusCode() != http.StatusUnauthorized {
+		t.Errorf("LastStatusCode = %d, want 401", s.LastStatusCode())
+	}
+}
@@ -0,0 +1,87 @@
+package sinks
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+	"wsrepeater/internal/config"
+	"wsrepeater/internal/weather"
+)
+
+const wowURL = "https://wow.metoffice.gov.uk/automaticreading"
+
+// WOW keeps at most one reading per site every 5 minutes
+const wowMinInterval = 5 * time.Minute
+
+func init() {
+	Register("wow", newWOW)
+}
+
+// WOW uploads observations to the Met Office Weather Observations Website,
+// whose automatic reading API takes Wunderground-style parameters.
+type WOW struct {
+	url      string
+	siteID   string
+	key      string
+	software string
+	client   *http.Client
+
+	httpStatus
+}
+
+func newWOW() (Sink, error) {
+	siteID := os.Getenv("WOW_SITE_ID")
+	key := os.Getenv("WOW_AUTH_KEY")
+	if siteID == "" || key == "" {
+		return nil, fmt.Errorf("WOW_SITE_ID and WOW_AUTH_KEY must be set")
+	}
+
+	return &WOW{
+		url:      config.GetString("WOW_URL", wowURL),
+		siteID:   siteID,
+		key:      key,
+		software: os.Getenv("STATION_SOFTWARE"),
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func (s *WOW) Name() string {
+	return "wow"
+}
+
+func (s *WOW) MinInterval() time.Duration {
+	return wowMinInterval
+}
+
+func (s *WOW) Send(obs *weather.Observation) error {
+	data := wundergroundValues(obs)
+
+	// WOW only accepts the outdoor subset of the Wunderground parameters
+	for _, key := range []string{"absbaromin", "weeklyrainin", "monthlyrainin", "yearlyrainin", "indoortempf", "indoorhumidity"} {
+		data.Del(key)
+	}
+	data.Set("siteid", s.siteID)
+	data.Set("siteAuthenticationKey", s.key)
+	data.Set("softwaretype", s.software)
+
+	resp, err := s.client.Get(s.url + "?" + data.Encode())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	s.record(resp)
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading response: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("received non-OK HTTP status: %v: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package sinks
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestWOWSend(t *testing.T) {
+	var query url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+	}))
+	defer server.Close()
+
+	s := &WOW{url: server.URL, siteID: "site", key: "123456", software: "test", client: server.Client()}
+	if err := s.Send(testObservation(t)); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	want := map[string]string{
+		"siteid":                "site",
+		"siteAuthenticationKey": "123456",
+		"softwaretype":          "test",
+		"dateutc":               "2026-01-02 03:04:05",
+		"tempf":                 "50",
+		"humidity":              "80",
+		"baromin":               "29.92",
+		"winddir":               "180",
+		"dailyrainin":           "0.25",
+	}
+	for key, value := range want {
+		if got := query.Get(key); got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+	if query.Has("absbaromin") {
+		t.Error("absbaromin sent, but WOW doesn't accept it")
+	}
+}
+
+func TestWOWSendError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unknown site", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	s := &WOW{url: server.URL, client: server.Client()}
+	if err := s.Send(testObservation(t)); err == nil {
+		t.Fatal("Send succeeded on a 403 response")
+	}
+}
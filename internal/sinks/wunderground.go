@@ -0,0 +1,200 @@
+package sinks
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"wsrepeater/internal/config"
+	"wsrepeater/internal/weather"
+)
+
+const (
+	wundergroundURL          = "http://weatherstation.wunderground.com/weatherstation/updateweatherstation.php"
+	wundergroundRapidFireURL = "http://rtupdate.wunderground.com/weatherstation/updateweatherstation.php"
+)
+
+// Errors reported by Weather Underground, distinguished so that the upload
+// status shows what needs fixing.
+var (
+	ErrBadPassword    = errors.New("station ID or password rejected")
+	ErrRateLimited    = errors.New("rate limited")
+	ErrStationUnknown = errors.New("station ID unknown")
+)
+
+func init() {
+	Register("wunderground", newWunderground)
+}
+
+// Wunderground uploads observations with the Weather Underground PWS
+// "updateweatherstation" protocol. Current observations go to the RapidFire
+// server when enabled. Observations older than backfillAge, replayed from the
+// queue after an outage, are uploaded as regular historical reports at their
+// original time, at most one per backfillInterval.
+type Wunderground struct {
+	url          string
+	rapidFireURL string
+	id           string
+	password     string
+	software     string
+	client       *http.Client
+
+	rapidFire        bool
+	interval         time.Duration
+	backfill         bool
+	backfillAge      time.Duration
+	backfillInterval time.Duration
+	lastBackfill     time.Time
+
+	httpStatus
+}
+
+func newWunderground() (Sink, error) {
+	id := os.Getenv("WUNDERGROUND_ID")
+	password := os.Getenv("WUNDERGROUND_PASS")
+	if id == "" || password == "" {
+		return nil, fmt.Errorf("WUNDERGROUND_ID and WUNDERGROUND_PASS must be set")
+	}
+
+	return &Wunderground{
+		url:              config.GetString("WUNDERGROUND_URL", wundergroundURL),
+		rapidFireURL:     config.GetString("WUNDERGROUND_RAPIDFIRE_URL", wundergroundRapidFireURL),
+		id:               id,
+		password:         password,
+		software:         os.Getenv("STATION_SOFTWARE"),
+		client:           &http.Client{Timeout: 30 * time.Second},
+		rapidFire:        config.GetBool("WUNDERGROUND_RAPIDFIRE", true),
+		interval:         config.GetDuration("WUNDERGROUND_INTERVAL", 0),
+		backfill:         config.GetBool("WUNDERGROUND_BACKFILL", true),
+		backfillAge:      config.GetDuration("WUNDERGROUND_BACKFILL_AGE", 5*time.Minute),
+		backfillInterval: config.GetDuration("WUNDERGROUND_BACKFILL_INTERVAL", 2*time.Second),
+	}, nil
+}
+
+func (s *Wunderground) Name() string {
+	return "wunderground"
+}
+
+func (s *Wunderground) Send(obs *weather.Observation) error {
+	data := wundergroundValues(obs)
+	data.Set("ID", s.id)
+	data.Set("PASSWORD", s.password)
+	data.Set("softwaretype", s.software)
+	data.Set("action", "updateraw")
+
+	target := s.url
+	switch {
+	case s.backfill && time.Since(obs.Time) > s.backfillAge:
+		s.pace()
+	case s.rapidFire:
+		target = s.rapidFireURL
+		data.Set("realtime", "1")
+		if rtfreq := s.rtfreq(obs); rtfreq > 0 {
+			data.Set("rtfreq", strconv.Itoa(rtfreq))
+		}
+	}
+
+	resp, err := s.client.PostForm(target, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	s.record(resp)
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading response: %v", err)
+	}
+
+	return wundergroundError(resp.StatusCode, string(respBody))
+}
+
+// rtfreq returns the number of seconds between two RapidFire uploads: the
+// gateway's report interval, or the upload interval if reports are decimated.
+func (s *Wunderground) rtfreq(obs *weather.Observation) int {
+	seconds := int(s.interval / time.Second)
+	if obs.Interval != nil && int(*obs.Interval) > seconds {
+		seconds = int(*obs.Interval)
+	}
+	return seconds
+}
+
+// pace waits until backfillInterval has passed since the previous backfill
+// upload, so that replaying a backlog doesn't get the station rate limited.
+func (s *Wunderground) pace() {
+	if wait := time.Until(s.lastBackfill.Add(s.backfillInterval)); wait > 0 {
+		time.Sleep(wait)
+	}
+	s.lastBackfill = time.Now()
+}
+
+// wundergroundError classifies an updateweatherstation response, returning
+// nil for success.
+func wundergroundError(status int, respBody string) error {
+	body := strings.TrimSpace(respBody)
+	lower := strings.ToLower(body)
+
+	switch {
+	case status == http.StatusOK && strings.Contains(lower, "success"):
+		return nil
+	case status == http.StatusTooManyRequests || strings.Contains(lower, "too many") || strings.Contains(lower, "rate limit"):
+		return fmt.Errorf("%w: %s", ErrRateLimited, body)
+	case strings.Contains(lower, "station") && (strings.Contains(lower, "not found") || strings.Contains(lower, "unknown")):
+		return fmt.Errorf("%w: %s", ErrStationUnknown, body)
+	case strings.Contains(lower, "invalidpasswordid") || strings.Contains(lower, "password") || status == http.StatusUnauthorized:
+		return fmt.Errorf("%w: %s", ErrBadPassword, body)
+	case status != http.StatusOK:
+		return fmt.Errorf("received non-OK HTTP status: %v: %s", status, body)
+	default:
+		return fmt.Errorf("unexpected response: %s", body)
+	}
+}
+
+// wundergroundValues builds the measurement part of an updateweatherstation
+// request. Other services that speak the same protocol reuse it with their
+// own credentials.
+func wundergroundValues(obs *weather.Observation) url.Values {
+	data := url.Values{}
+	data.Set("dateutc", obs.DateUTC())
+	setValue(data, "tempf", obs.TempF)
+	setValue(data, "humidity", obs.Humidity)
+	setFixed(data, "dewptf", obs.Derived().DewPointF, 2)
+	setFixed(data, "windspeedmph", obs.WindSpeedMph, 2)
+	setValue(data, "windgustmph", obs.WindGustMph)
+	setValue(data, "winddir", obs.WindDir)
+	setFixed(data, "solarradiation", obs.SolarRadiation, 2)
+	setFixed(data, "UV", obs.UV, 0)
+	setValue(data, "baromin", obs.BaromRelIn)
+	setValue(data, "absbaromin", obs.BaromAbsIn)
+	setValue(data, "rainin", obs.RainRateIn)
+	setValue(data, "dailyrainin", obs.DailyRainIn)
+	setValue(data, "weeklyrainin", obs.WeeklyRainIn)
+	setValue(data, "monthlyrainin", obs.MonthlyRainIn)
+	setValue(data, "yearlyrainin", obs.YearlyRainIn)
+	setValue(data, "indoortempf", obs.IndoorTempF)
+	setValue(data, "indoorhumidity", obs.IndoorHumidity)
+
+	return data
+}
+
+// setValue sets key to the formatted value of p. Sensors missing from the
+// report are left out of the upload rather than sent as empty strings.
+func setValue(values url.Values, key string, p *float64) {
+	if p == nil {
+		return
+	}
+	values.Set(key, weather.FormatFloat(*p))
+}
+
+// setFixed is setValue with a fixed number of decimals.
+func setFixed(values url.Values, key string, p *float64, decimals int) {
+	if p == nil {
+		return
+	}
+	values.Set(key, strconv.FormatFloat(*p, 'f', decimals, 64))
+}
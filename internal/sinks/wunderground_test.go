@@ -0,0 +1,126 @@
+package sinks
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestWundergroundSend(t *testing.T) {
+	tests := []struct {
+		name         string
+		rapidFire    bool
+		backfill     bool
+		wantPath     string
+		wantRealtime string
+	}{
+		{"regular", false, false, "/regular", ""},
+		{"rapidfire", true, false, "/rapidfire", "1"},
+		{"backfill", true, true, "/regular", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var path string
+			var form url.Values
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				r.ParseForm()
+				path, form = r.URL.Path, r.PostForm
+				fmt.Fprintln(w, "success")
+			}))
+			defer server.Close()
+
+			s := &Wunderground{
+				url:          server.URL + "/regular",
+				rapidFireURL: server.URL + "/rapidfire",
+				id:           "KXX123",
+				password:     "secret",
+				software:     "wsrepeater",
+				client:       server.Client(),
+				rapidFire:    tt.rapidFire,
+				backfill:     tt.backfill,
+				backfillAge:  5 * time.Minute,
+			}
+			if err := s.Send(testObservation(t)); err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+
+			if path != tt.wantPath {
+				t.Errorf("posted to %q, want %q", path, tt.wantPath)
+			}
+			want := map[string]string{
+				"ID":           "KXX123",
+				"PASSWORD":     "secret",
+				"action":       "updateraw",
+				"softwaretype": "wsrepeater",
+				"dateutc":      "2026-01-02 03:04:05",
+				"tempf":        "50",
+				"humidity":     "80",
+				"baromin":      "29.92",
+				"dailyrainin":  "0.25",
+				"realtime":     tt.wantRealtime,
+			}
+			for key, value := range want {
+				if got := form.Get(key); got != value {
+					t.Errorf("%s = %q, want %q", key, got, value)
+				}
+			}
+		})
+	}
+}
+
+func TestWundergroundSendRejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprintln(w, "INVALIDPASSWORDID|Password or key and/or id are incorrect")
+	}))
+	defer server.Close()
+
+	s := &Wunderground{url: server.URL, client: server.Client()}
+	if err := s.Send(testObservation(t)); !errors.Is(err, ErrBadPassword) {
+		t.Fatalf("Send = %v, want ErrBadPassword", err)
+	}
+	if s.LastStatusCode() != http.StatusUnauthorized {
+		t.Errorf("LastStatusCode = %d, want 401", s.LastStatusCode())
+	}
+}
+
+func TestWundergroundError(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+		want   error
+	}{
+		{http.StatusOK, "success\n", nil},
+		{http.StatusOK, "INVALIDPASSWORDID|Password or key and/or id are incorrect", ErrBadPassword},
+		{http.StatusUnauthorized, "", ErrBadPassword},
+		{http.StatusTooManyRequests, "", ErrRateLimited},
+		{http.StatusOK, "Too many requests", ErrRateLimited},
+		{http.StatusOK, "Station not found", ErrStationUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d %s", tt.status, tt.body), func(t *testing.T) {
+			err := wundergroundError(tt.status, tt.body)
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("error = %v, want success", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	for _, status := range []int{http.StatusOK, http.StatusInternalServerError} {
+		if err := wundergroundError(status, "unexpected"); err == nil {
+			t.Errorf("status %d with an unexpected body succeeded", status)
+		}
+	}
+}
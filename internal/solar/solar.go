@@ -0,0 +1,79 @@
+// Package solar models the sun's position and the irradiance a horizontal
+// sensor receives under a clear sky, to sanity-check solar radiation readings.
+package solar
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// solarConstant is the mean extraterrestrial irradiance, in W/m².
+	solarConstant = 1361.0
+	// standardPressure is sea-level pressure in hPa, at which air mass is 1
+	// with the sun overhead.
+	standardPressure = 1013.25
+	// DefaultTransmittance is the clear-sky atmospheric transmittance of the
+	// Meinel model.
+	DefaultTransmittance = 0.7
+)
+
+// Elevation returns the sun's elevation above the horizon in degrees, at t
+// for the location lat, lon (degrees, east and north positive). It uses the
+// NOAA approximation, good to a fraction of a degree.
+func Elevation(t time.Time, lat, lon float64) float64 {
+	t = t.UTC()
+	hour := float64(t.Hour()) + float64(t.Minute())/60 + float64(t.Second())/3600
+	gamma := 2 * math.Pi / 365 * (float64(t.YearDay()-1) + (hour-12)/24)
+
+	// Equation of time in minutes, declination in radians
+	eqTime := 229.18 * (0.000075 + 0.001868*math.Cos(gamma) - 0.032077*math.Sin(gamma) -
+		0.014615*math.Cos(2*gamma) - 0.040849*math.Sin(2*gamma))
+	decl := 0.006918 - 0.399912*math.Cos(gamma) + 0.070257*math.Sin(gamma) -
+		0.006758*math.Cos(2*gamma) + 0.000907*math.Sin(2*gamma) -
+		0.002697*math.Cos(3*gamma) + 0.00148*math.Sin(3*gamma)
+
+	trueSolarTime := hour*60 + eqTime + 4*lon
+	hourAngle := radians(trueSolarTime/4 - 180)
+	phi := radians(lat)
+
+	cosZenith := math.Sin(phi)*math.Sin(decl) + math.Cos(phi)*math.Cos(decl)*math.Cos(hourAngle)
+	cosZenith = math.Max(-1, math.Min(1, cosZenith))
+	return 90 - degrees(math.Acos(cosZenith))
+}
+
+// AirMass returns the relative optical path length through the atmosphere
+// for the sun at elevation degrees (Kasten and Young 1989), corrected for
+// the station pressure in hPa.
+func AirMass(elevation, pressure float64) float64 {
+	if elevation <= 0 {
+		return math.Inf(1)
+	}
+	zenith := 90 - elevation
+	am := 1 / (math.Cos(radians(zenith)) + 0.50572*math.Pow(96.07995-zenith, -1.6364))
+	if pressure > 0 {
+		am *= pressure / standardPressure
+	}
+	return am
+}
+
+// ClearSky returns the global horizontal irradiance in W/m² expected under a
+// cloudless sky at t and lat, lon. The direct beam is attenuated by the
+// transmittance raised to the air mass (Meinel), and diffuse light adds
+// another 10%. pressure is the station pressure in hPa, or zero for sea
+// level.
+func ClearSky(t time.Time, lat, lon, pressure, transmittance float64) float64 {
+	elevation := Elevation(t, lat, lon)
+	if elevation <= 0 {
+		return 0
+	}
+
+	// Earth-sun distance varies the extraterrestrial irradiance by ±3.3%
+	extraterrestrial := solarConstant * (1 + 0.033*math.Cos(2*math.Pi*float64(t.UTC().YearDay())/365))
+	direct := extraterrestrial * math.Pow(transmittance, math.Pow(AirMass(elevation, pressure), 0.678))
+
+	return 1.1 * direct * math.Sin(radians(elevation))
+}
+
+func radians(d float64) float64 { return d * math.Pi / 180 }
+func degrees(r float64) float64 { return r * 180 / math.Pi }
@@ -0,0 +1,196 @@
+package weather
+
+import "math"
+
+// Magnus coefficients for saturation vapor pressure over water, shared by
+// every humidity-derived quantity so they agree with each other.
+const (
+	magnusA = 17.27
+	magnusB = 237.7
+	magnusC = 6.112 // hPa
+
+	// Specific gas constants of dry air and water vapor, in J/(kg·K)
+	gasConstantDryAir = 287.05
+	gasConstantVapor  = 461.5
+)
+
+// derivedKeys name the derived quantities in Values and Get.
+var derivedKeys = []string{
+	"dewptf",
+	"heatindexf",
+	"humidex",
+	"windchillf",
+	"feelslikef",
+	"wetbulbf",
+	"vaporpressure",
+	"absolutehumidity",
+	"airdensity",
+}
+
+// DerivedKeys returns the keys of the quantities computed by Derived.
+func DerivedKeys() []string {
+	return append([]string(nil), derivedKeys...)
+}
+
+// Derived holds the quantities computed from an observation's outdoor
+// measurements. Each is nil when its inputs are missing or it is undefined
+// for the conditions (wind chill above 10 °C, for one).
+type Derived struct {
+	DewPointF        *float64 // dewptf
+	HeatIndexF       *float64 // heatindexf, NWS Rothfusz regression
+	Humidex          *float64 // humidex, Environment Canada, in °C
+	WindChillF       *float64 // windchillf, Environment Canada
+	FeelsLikeF       *float64 // feelslikef
+	WetBulbF         *float64 // wetbulbf, Stull 2011
+	VaporPressure    *float64 // vaporpressure, hPa
+	AbsoluteHumidity *float64 // absolutehumidity, g/m³
+	AirDensity       *float64 // airdensity, kg/m³, from absolute pressure
+}
+
+// Derived computes the derived quantities of the observation.
+func (o *Observation) Derived() *Derived {
+	d := &Derived{}
+	if o.TempF == nil {
+		return d
+	}
+
+	tempF := *o.TempF
+	tempC := FahrenheitToCelsius(tempF)
+	tempK := tempC + 273.15
+
+	var windKmh *float64
+	if o.WindSpeedMph != nil {
+		windKmh = ptr(MphToKmh(*o.WindSpeedMph))
+	}
+	if wc, ok := WindChill(tempC, windKmh); ok {
+		d.WindChillF = ptr(CelsiusToFahrenheit(wc))
+	}
+
+	if o.Humidity != nil && *o.Humidity > 0 {
+		rh := *o.Humidity
+		e := VaporPressure(tempC, rh)
+		dewPointC := DewPoint(tempC, rh)
+
+		d.VaporPressure = &e
+		d.DewPointF = ptr(CelsiusToFahrenheit(dewPointC))
+		d.HeatIndexF = ptr(HeatIndex(tempF, rh))
+		d.Humidex = ptr(Humidex(tempC, dewPointC))
+		d.WetBulbF = ptr(CelsiusToFahrenheit(WetBulb(tempC, rh)))
+		d.AbsoluteHumidity = ptr(e * 100 / (gasConstantVapor * tempK) * 1000)
+
+		if o.BaromAbsIn != nil {
+			p := InHgToHPa(*o.BaromAbsIn)
+			density := (p-e)*100/(gasConstantDryAir*tempK) + e*100/(gasConstantVapor*tempK)
+			d.AirDensity = &density
+		}
+	}
+
+	// Feels like: wind chill when cold, heat index when hot
+	switch {
+	case d.WindChillF != nil:
+		d.FeelsLikeF = ptr(*d.WindChillF)
+	case d.HeatIndexF != nil && tempF >= 80:
+		d.FeelsLikeF = ptr(*d.HeatIndexF)
+	default:
+		d.FeelsLikeF = ptr(tempF)
+	}
+
+	return d
+}
+
+// Get returns the derived quantity key, one of DerivedKeys.
+func (d *Derived) Get(key string) (float64, bool) {
+	var p *float64
+	switch key {
+	case "dewptf":
+		p = d.DewPointF
+	case "heatindexf":
+		p = d.HeatIndexF
+	case "humidex":
+		p = d.Humidex
+	case "windchillf":
+		p = d.WindChillF
+	case "feelslikef":
+		p = d.FeelsLikeF
+	case "wetbulbf":
+		p = d.WetBulbF
+	case "vaporpressure":
+		p = d.VaporPressure
+	case "absolutehumidity":
+		p = d.AbsoluteHumidity
+	case "airdensity":
+		p = d.AirDensity
+	}
+	if p == nil {
+		return 0, false
+	}
+	return *p, true
+}
+
+// SaturationVaporPressure returns the saturation vapor pressure over water
+// at tempC, in hPa (Magnus formula).
+func SaturationVaporPressure(tempC float64) float64 {
+	return magnusC * math.Exp(magnusA*tempC/(magnusB+tempC))
+}
+
+// VaporPressure returns the partial pressure of water vapor in hPa.
+func VaporPressure(tempC, humidity float64) float64 {
+	return humidity / 100 * SaturationVaporPressure(tempC)
+}
+
+// DewPoint returns the dew point in °C.
+func DewPoint(tempC, humidity float64) float64 {
+	alpha := magnusA*tempC/(magnusB+tempC) + math.Log(humidity/100)
+	return magnusB * alpha / (magnusA - alpha)
+}
+
+// HeatIndex returns the NWS heat index in °F: Steadman's simple formula,
+// or the Rothfusz regression with its adjustments once that reaches 80 °F.
+func HeatIndex(tempF, humidity float64) float64 {
+	hi := 0.5 * (tempF + 61 + (tempF-68)*1.2 + humidity*0.094)
+	if (hi+tempF)/2 < 80 {
+		return hi
+	}
+
+	t, rh := tempF, humidity
+	hi = -42.379 + 2.04901523*t + 10.14333127*rh - 0.22475541*t*rh -
+		0.00683783*t*t - 0.05481717*rh*rh + 0.00122874*t*t*rh +
+		0.00085282*t*rh*rh - 0.00000199*t*t*rh*rh
+
+	switch {
+	case rh < 13 && t >= 80 && t <= 112:
+		hi -= (13 - rh) / 4 * math.Sqrt((17-math.Abs(t-95))/17)
+	case rh > 85 && t >= 80 && t <= 87:
+		hi += (rh - 85) / 10 * (87 - t) / 5
+	}
+	return hi
+}
+
+// Humidex returns the Environment Canada humidex from the temperature and
+// dew point in °C.
+func Humidex(tempC, dewPointC float64) float64 {
+	e := 6.11 * math.Exp(5417.7530*(1/273.16-1/(dewPointC+273.15)))
+	return tempC + 0.5555*(e-10)
+}
+
+// WindChill returns the Environment Canada wind chill index in °C. It is
+// only defined at or below 10 °C with wind above 4.8 km/h.
+func WindChill(tempC float64, windKmh *float64) (float64, bool) {
+	if windKmh == nil || tempC > 10 || *windKmh <= 4.8 {
+		return 0, false
+	}
+	v := math.Pow(*windKmh, 0.16)
+	return 13.12 + 0.6215*tempC - 11.37*v + 0.3965*tempC*v, true
+}
+
+// WetBulb returns the wet-bulb temperature in °C at sea-level pressure
+// (Stull 2011), accurate to about 0.3 °C between 5% and 99% humidity.
+func WetBulb(tempC, humidity float64) float64 {
+	t, rh := tempC, humidity
+	return t*math.Atan(0.151977*math.Sqrt(rh+8.313659)) + math.Atan(t+rh) -
+		math.Atan(rh-1.676331) + 0.00391838*math.Pow(rh, 1.5)*math.Atan(0.023101*rh) - 4.686035
+}
+
+func ptr(v float64) *float64 {
+	return &v
+}
@@ -0,0 +1,134 @@
+package weather
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDewPoint(t *testing.T) {
+	tests := []struct {
+		tempC, humidity, want float64
+	}{
+		{20, 50, 9.3},
+		{30, 70, 23.9},
+		{25, 100, 25},
+		{0, 80, -3.0},
+		{-10, 60, -16.3},
+	}
+	for _, tt := range tests {
+		if got := DewPoint(tt.tempC, tt.humidity); math.Abs(got-tt.want) > 0.1 {
+			t.Errorf("DewPoint(%v, %v) = %.2f, want %v", tt.tempC, tt.humidity, got, tt.want)
+		}
+	}
+}
+
+// The expected values below are read from the Environment Canada humidex
+// and wind chill tables and the NWS heat index chart, which round to whole
+// degrees.
+
+func TestHumidex(t *testing.T) {
+	tests := []struct {
+		tempC, dewPointC, want float64
+	}{
+		{30, 15, 34},
+		{35, 25, 47},
+		{25, 10, 26},
+	}
+	for _, tt := range tests {
+		if got := Humidex(tt.tempC, tt.dewPointC); math.Abs(got-tt.want) > 0.5 {
+			t.Errorf("Humidex(%v, %v) = %.2f, want %v", tt.tempC, tt.dewPointC, got, tt.want)
+		}
+	}
+}
+
+func TestWindChill(t *testing.T) {
+	tests := []struct {
+		name    string
+		tempC   float64
+		windKmh *float64
+		want    float64
+		ok      bool
+	}{
+		{"cold and windy", -20, ptr(30), -33, true},
+		{"freezing", 0, ptr(10), -3, true},
+		{"moderate", -10, ptr(20), -18, true},
+		{"extreme", -30, ptr(50), -49, true},
+		{"at the temperature limit", 10, ptr(20), 7, true},
+		{"too warm", 10.5, ptr(20), 0, false},
+		{"calm", -20, ptr(4.8), 0, false},
+		{"no wind reading", -20, nil, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := WindChill(tt.tempC, tt.windKmh)
+			if ok != tt.ok {
+				t.Fatalf("defined = %v, want %v", ok, tt.ok)
+			}
+			if ok && math.Abs(got-tt.want) > 0.5 {
+				t.Errorf("WindChill = %.2f, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeatIndex(t *testing.T) {
+	tests := []struct {
+		tempF, humidity, want float64
+	}{
+		{90, 60, 100},
+		{100, 40, 109},
+		{80, 40, 80},
+		{86, 90, 105},
+		{70, 50, 69},
+	}
+	for _, tt := range tests {
+		if got := HeatIndex(tt.tempF, tt.humidity); math.Abs(got-tt.want) > 0.5 {
+			t.Errorf("HeatIndex(%v, %v) = %.2f, want %v", tt.tempF, tt.humidity, got, tt.want)
+		}
+	}
+}
+
+func TestWetBulb(t *testing.T) {
+	// Stull's worked example
+	if got := WetBulb(20, 50); math.Abs(got-13.7) > 0.05 {
+		t.Errorf("WetBulb(20, 50) = %.2f, want 13.7", got)
+	}
+}
+
+func TestDerivedFeelsLike(t *testing.T) {
+	tests := []struct {
+		name          string
+		obs           Observation
+		wantFeelsLike float64
+		wantWindChill bool
+	}{
+		{"wind chill", Observation{TempF: ptr(32), Humidity: ptr(80), WindSpeedMph: ptr(6.2)}, 26, true},
+		{"heat index", Observation{TempF: ptr(90), Humidity: ptr(60), WindSpeedMph: ptr(6.2)}, 100, false},
+		{"mild", Observation{TempF: ptr(70), Humidity: ptr(50)}, 70, false},
+		{"calm cold", Observation{TempF: ptr(32), Humidity: ptr(80), WindSpeedMph: ptr(2)}, 32, false},
+		{"no humidity", Observation{TempF: ptr(95)}, 95, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := tt.obs.Derived()
+			if d.FeelsLikeF == nil || math.Abs(*d.FeelsLikeF-tt.wantFeelsLike) > 0.5 {
+				t.Errorf("feels like = %v, want %v", d.FeelsLikeF, tt.wantFeelsLike)
+			}
+			if (d.WindChillF != nil) != tt.wantWindChill {
+				t.Errorf("wind chill = %v, want defined %v", d.WindChillF, tt.wantWindChill)
+			}
+			if (d.DewPointF != nil) != (tt.obs.Humidity != nil) {
+				t.Errorf("dew point = %v with humidity %v", d.DewPointF, tt.obs.Humidity)
+			}
+		})
+	}
+}
+
+func TestDerivedWithoutTemperature(t *testing.T) {
+	d := (&Observation{Humidity: ptr(50)}).Derived()
+	for _, key := range DerivedKeys() {
+		if v, ok := d.Get(key); ok {
+			t.Errorf("%s = %v without a temperature", key, v)
+		}
+	}
+}
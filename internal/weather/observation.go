@@ -0,0 +1,471 @@
+package weather
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Ecowitt sends the report time in UTC using this layout, or the literal "now".
+const ecowittTimeLayout = "2006-01-02 15:04:05"
+
+const (
+	channelCount = 8
+	soilCount    = 8
+	pm25Count    = 4
+	leakCount    = 4
+)
+
+// Observation is a single report from the Ecowitt gateway. Every measurement
+// is nullable so that a sensor that is missing from a report can be told
+// apart from a sensor that reads zero. Values are stored in the imperial units
+// the gateway sends them in; use Metric or Imperial for converted views.
+type Observation struct {
+	Time        time.Time `json:"time"`
+	PassKey     string    `json:"-"`
+	StationType string    `json:"stationtype,omitempty"`
+	Model       string    `json:"model,omitempty"`
+	Frequency   string    `json:"freq,omitempty"`
+	Runtime     *float64  `json:"runtime,omitempty"`
+	Interval    *float64  `json:"interval,omitempty"`
+
+	IndoorTempF    *float64 `json:"tempinf,omitempty"`
+	IndoorHumidity *float64 `json:"humidityin,omitempty"`
+	BaromRelIn     *float64 `json:"baromrelin,omitempty"`
+	BaromAbsIn     *float64 `json:"baromabsin,omitempty"`
+
+	TempF    *float64 `json:"tempf,omitempty"`
+	Humidity *float64 `json:"humidity,omitempty"`
+	VPD      *float64 `json:"vpd,omitempty"`
+
+	WindDir         *float64 `json:"winddir,omitempty"`
+	WindSpeedMph    *float64 `json:"windspeedmph,omitempty"`
+	WindGustMph     *float64 `json:"windgustmph,omitempty"`
+	MaxDailyGustMph *float64 `json:"maxdailygust,omitempty"`
+
+	SolarRadiation *float64 `json:"solarradiation,omitempty"`
+	UV             *float64 `json:"uv,omitempty"`
+
+	RainRateIn    *float64 `json:"rainratein,omitempty"`
+	EventRainIn   *float64 `json:"eventrainin,omitempty"`
+	HourlyRainIn  *float64 `json:"hourlyrainin,omitempty"`
+	DailyRainIn   *float64 `json:"dailyrainin,omitempty"`
+	WeeklyRainIn  *float64 `json:"weeklyrainin,omitempty"`
+	MonthlyRainIn *float64 `json:"monthlyrainin,omitempty"`
+	YearlyRainIn  *float64 `json:"yearlyrainin,omitempty"`
+	TotalRainIn   *float64 `json:"totalrainin,omitempty"`
+
+	LightningKm    *float64 `json:"lightning,omitempty"`
+	LightningCount *float64 `json:"lightning_num,omitempty"`
+	LightningTime  *float64 `json:"lightning_time,omitempty"`
+
+	Battery  Battery               `json:"battery"`
+	Channels [channelCount]Channel `json:"channels"`
+	Soil     [soilCount]Soil       `json:"soil"`
+	PM25     [pm25Count]PM25       `json:"pm25"`
+	Leak     [leakCount]Leak       `json:"leak"`
+
+	// Extra keeps any parameter the gateway sent that this package does not
+	// know about, so that it is still visible on the dashboard.
+	Extra map[string]string `json:"extra,omitempty"`
+
+	// Missing lists the expected parameters that were absent from the report
+	// or failed validation, e.g. while the outdoor array has lost its RF link.
+	Missing []string `json:"missing,omitempty"`
+	// Invalid lists the parameters that were present but rejected.
+	Invalid []string `json:"invalid,omitempty"`
+}
+
+// Battery holds the battery flags and voltages of the gateway-level sensors.
+// The WH65 and WH26 report 0 for OK and 1 for low, the others report a level
+// from 0 to 5 or a voltage.
+type Battery struct {
+	WH65     *float64 `json:"wh65batt,omitempty"`
+	WH80     *float64 `json:"wh80batt,omitempty"`
+	WH25     *float64 `json:"wh25batt,omitempty"`
+	WH26     *float64 `json:"wh26batt,omitempty"`
+	WH40     *float64 `json:"wh40batt,omitempty"`
+	WH57     *float64 `json:"wh57batt,omitempty"`
+	WH68     *float64 `json:"wh68batt,omitempty"`
+	WS90Volt *float64 `json:"ws90cap_volt,omitempty"`
+}
+
+// Channel is one of the WH31 multi-channel temperature/humidity sensors.
+type Channel struct {
+	TempF    *float64 `json:"tempf,omitempty"`
+	Humidity *float64 `json:"humidity,omitempty"`
+	Battery  *float64 `json:"batt,omitempty"`
+}
+
+// Soil is one of the WH51 soil moisture sensors.
+type Soil struct {
+	Moisture *float64 `json:"moisture,omitempty"`
+	Battery  *float64 `json:"batt,omitempty"`
+}
+
+// PM25 is one of the WH41/WH43 air quality sensors.
+type PM25 struct {
+	Value   *float64 `json:"pm25,omitempty"`
+	Avg24h  *float64 `json:"pm25_avg_24h,omitempty"`
+	Battery *float64 `json:"batt,omitempty"`
+}
+
+// Leak is one of the WH55 water leak sensors.
+type Leak struct {
+	Leak    *float64 `json:"leak,omitempty"`
+	Battery *float64 `json:"batt,omitempty"`
+}
+
+type field struct {
+	key string
+	ptr func(o *Observation) **float64
+}
+
+// fields maps every numeric Ecowitt parameter to its place in Observation.
+var fields = buildFields()
+
+func buildFields() []field {
+	f := []field{
+		{"runtime", func(o *Observation) **float64 { return &o.Runtime }},
+		{"interval", func(o *Observation) **float64 { return &o.Interval }},
+		{"tempinf", func(o *Observation) **float64 { return &o.IndoorTempF }},
+		{"humidityin", func(o *Observation) **float64 { return &o.IndoorHumidity }},
+		{"baromrelin", func(o *Observation) **float64 { return &o.BaromRelIn }},
+		{"baromabsin", func(o *Observation) **float64 { return &o.BaromAbsIn }},
+		{"tempf", func(o *Observation) **float64 { return &o.TempF }},
+		{"humidity", func(o *Observation) **float64 { return &o.Humidity }},
+		{"vpd", func(o *Observation) **float64 { return &o.VPD }},
+		{"winddir", func(o *Observation) **float64 { return &o.WindDir }},
+		{"windspeedmph", func(o *Observation) **float64 { return &o.WindSpeedMph }},
+		{"windgustmph", func(o *Observation) **float64 { return &o.WindGustMph }},
+		{"maxdailygust", func(o *Observation) **float64 { return &o.MaxDailyGustMph }},
+		{"solarradiation", func(o *Observation) **float64 { return &o.SolarRadiation }},
+		{"uv", func(o *Observation) **float64 { return &o.UV }},
+		{"rainratein", func(o *Observation) **float64 { return &o.RainRateIn }},
+		{"eventrainin", func(o *Observation) **float64 { return &o.EventRainIn }},
+		{"hourlyrainin", func(o *Observation) **float64 { return &o.HourlyRainIn }},
+		{"dailyrainin", func(o *Observation) **float64 { return &o.DailyRainIn }},
+		{"weeklyrainin", func(o *Observation) **float64 { return &o.WeeklyRainIn }},
+		{"monthlyrainin", func(o *Observation) **float64 { return &o.MonthlyRainIn }},
+		{"yearlyrainin", func(o *Observation) **float64 { return &o.YearlyRainIn }},
+		{"totalrainin", func(o *Observation) **float64 { return &o.TotalRainIn }},
+		{"lightning", func(o *Observation) **float64 { return &o.LightningKm }},
+		{"lightning_num", func(o *Observation) **float64 { return &o.LightningCount }},
+		{"lightning_time", func(o *Observation) **float64 { return &o.LightningTime }},
+		{"wh65batt", func(o *Observation) **float64 { return &o.Battery.WH65 }},
+		{"wh80batt", func(o *Observation) **float64 { return &o.Battery.WH80 }},
+		{"wh25batt", func(o *Observation) **float64 { return &o.Battery.WH25 }},
+		{"wh26batt", func(o *Observation) **float64 { return &o.Battery.WH26 }},
+		{"wh40batt", func(o *Observation) **float64 { return &o.Battery.WH40 }},
+		{"wh57batt", func(o *Observation) **float64 { return &o.Battery.WH57 }},
+		{"wh68batt", func(o *Observation) **float64 { return &o.Battery.WH68 }},
+		{"ws90cap_volt", func(o *Observation) **float64 { return &o.Battery.WS90Volt }},
+	}
+
+	for i := 0; i < channelCount; i++ {
+		i := i
+		n := i + 1
+		f = append(f,
+			field{fmt.Sprintf("temp%df", n), func(o *Observation) **float64 { return &o.Channels[i].TempF }},
+			field{fmt.Sprintf("humidity%d", n), func(o *Observation) **float64 { return &o.Channels[i].Humidity }},
+			field{fmt.Sprintf("batt%d", n), func(o *Observation) **float64 { return &o.Channels[i].Battery }},
+		)
+	}
+	for i := 0; i < soilCount; i++ {
+		i := i
+		n := i + 1
+		f = append(f,
+			field{fmt.Sprintf("soilmoisture%d", n), func(o *Observation) **float64 { return &o.Soil[i].Moisture }},
+			field{fmt.Sprintf("soilbatt%d", n), func(o *Observation) **float64 { return &o.Soil[i].Battery }},
+		)
+	}
+	for i := 0; i < pm25Count; i++ {
+		i := i
+		n := i + 1
+		f = append(f,
+			field{fmt.Sprintf("pm25_ch%d", n), func(o *Observation) **float64 { return &o.PM25[i].Value }},
+			field{fmt.Sprintf("pm25_avg_24h_ch%d", n), func(o *Observation) **float64 { return &o.PM25[i].Avg24h }},
+			field{fmt.Sprintf("pm25batt%d", n), func(o *Observation) **float64 { return &o.PM25[i].Battery }},
+		)
+	}
+	for i := 0; i < leakCount; i++ {
+		i := i
+		n := i + 1
+		f = append(f,
+			field{fmt.Sprintf("leak_ch%d", n), func(o *Observation) **float64 { return &o.Leak[i].Leak }},
+			field{fmt.Sprintf("leakbatt%d", n), func(o *Observation) **float64 { return &o.Leak[i].Battery }},
+		)
+	}
+
+	return f
+}
+
+// expectedFields are the parameters a WS2320 report carries when every sensor
+// is online. Their absence is recorded in Observation.Missing.
+var expectedFields = []string{
+	"tempinf", "humidityin", "baromrelin", "baromabsin",
+	"tempf", "humidity", "winddir", "windspeedmph", "windgustmph",
+	"solarradiation", "uv", "rainratein", "dailyrainin",
+}
+
+// validRanges bounds the values a sensor can physically report. Anything
+// outside is treated as a corrupted reading and dropped.
+var validRanges = map[string][2]float64{
+	"tempinf":        {-40, 160},
+	"tempf":          {-100, 160},
+	"humidityin":     {0, 100},
+	"humidity":       {0, 100},
+	"baromrelin":     {20, 35},
+	"baromabsin":     {15, 35},
+	"winddir":        {0, 360},
+	"windspeedmph":   {0, 250},
+	"windgustmph":    {0, 250},
+	"maxdailygust":   {0, 250},
+	"solarradiation": {0, 2000},
+	"uv":             {0, 20},
+	"rainratein":     {0, 100},
+	"eventrainin":    {0, 1000},
+	"hourlyrainin":   {0, 100},
+	"dailyrainin":    {0, 1000},
+	"weeklyrainin":   {0, 1000},
+	"monthlyrainin":  {0, 1000},
+	"yearlyrainin":   {0, 10000},
+	"totalrainin":    {0, 100000},
+}
+
+func lookupField(key string) (field, bool) {
+	for _, f := range fields {
+		if f.key == key {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+// IsKey reports whether key is an Ecowitt parameter known to Observation.
+func IsKey(key string) bool {
+	_, ok := lookupField(key)
+	return ok
+}
+
+// ParseEcowitt builds an Observation from the form-encoded body the Ecowitt
+// gateway POSTs in its "customized" upload mode. Each parameter is validated
+// on its own: values that don't parse or are out of range are left nil and
+// listed in Invalid, so a single bad sensor never discards the whole report.
+func ParseEcowitt(values url.Values) *Observation {
+	obs := &Observation{}
+
+	for key, vals := range values {
+		if len(vals) == 0 {
+			continue
+		}
+		raw := strings.TrimSpace(vals[0])
+
+		switch key {
+		case "PASSKEY":
+			obs.PassKey = raw
+			continue
+		case "stationtype":
+			obs.StationType = raw
+			continue
+		case "model":
+			obs.Model = raw
+			continue
+		case "freq":
+			obs.Frequency = raw
+			continue
+		case "dateutc":
+			t, err := parseEcowittTime(raw)
+			if err != nil {
+				obs.Invalid = append(obs.Invalid, key)
+				continue
+			}
+			obs.Time = t
+			continue
+		}
+
+		f, ok := lookupField(key)
+		if !ok {
+			if obs.Extra == nil {
+				obs.Extra = make(map[string]string)
+			}
+			obs.Extra[key] = raw
+			continue
+		}
+		if raw == "" {
+			continue
+		}
+
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) || !inRange(key, v) {
+			obs.Invalid = append(obs.Invalid, key)
+			continue
+		}
+		*f.ptr(obs) = &v
+	}
+
+	if obs.Time.IsZero() {
+		obs.Time = time.Now().UTC()
+	}
+
+	for _, key := range expectedFields {
+		if !obs.Has(key) {
+			obs.Missing = append(obs.Missing, key)
+		}
+	}
+	sort.Strings(obs.Invalid)
+
+	return obs
+}
+
+func inRange(key string, v float64) bool {
+	r, ok := validRanges[key]
+	if !ok {
+		return true
+	}
+	return v >= r[0] && v <= r[1]
+}
+
+// Empty reports whether the observation carries no measurements at all.
+func (o *Observation) Empty() bool {
+	for _, f := range fields {
+		if *f.ptr(o) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func parseEcowittTime(raw string) (time.Time, error) {
+	if raw == "" || raw == "now" {
+		return time.Now().UTC(), nil
+	}
+	return time.ParseInLocation(ecowittTimeLayout, raw, time.UTC)
+}
+
+// Get returns the value of the Ecowitt parameter key, if present, or of the
+// derived quantity key.
+func (o *Observation) Get(key string) (float64, bool) {
+	f, ok := lookupField(key)
+	if !ok {
+		return o.Derived().Get(key)
+	}
+	p := *f.ptr(o)
+	if p == nil {
+		return 0, false
+	}
+	return *p, true
+}
+
+// Set stores v as the value of the Ecowitt parameter key.
+func (o *Observation) Set(key string, v float64) bool {
+	f, ok := lookupField(key)
+	if !ok {
+		return false
+	}
+	*f.ptr(o) = &v
+	return true
+}
+
+// Has reports whether the Ecowitt parameter key is present.
+func (o *Observation) Has(key string) bool {
+	_, ok := o.Get(key)
+	return ok
+}
+
+// DateUTC formats the observation time the way Ecowitt and Wunderground expect.
+func (o *Observation) DateUTC() string {
+	return o.Time.UTC().Format(ecowittTimeLayout)
+}
+
+// Values returns the observation keyed by Ecowitt parameter names, plus the
+// derived quantities, the shape the dashboard reads from /latest.
+func (o *Observation) Values() map[string]string {
+	values := make(map[string]string, len(o.Extra)+len(fields))
+	for key, v := range o.Extra {
+		values[key] = v
+	}
+
+	values["dateutc"] = o.DateUTC()
+	if o.StationType != "" {
+		values["stationtype"] = o.StationType
+	}
+	if o.Model != "" {
+		values["model"] = o.Model
+	}
+	if o.Frequency != "" {
+		values["freq"] = o.Frequency
+	}
+
+	for _, f := range fields {
+		if p := *f.ptr(o); p != nil {
+			values[f.key] = FormatFloat(*p)
+		}
+	}
+
+	derived := o.Derived()
+	for _, key := range derivedKeys {
+		if v, ok := derived.Get(key); ok {
+			values[key] = strconv.FormatFloat(v, 'f', 2, 64)
+		}
+	}
+
+	if len(o.Missing) > 0 {
+		values["missing"] = strings.Join(o.Missing, ",")
+	}
+	if len(o.Invalid) > 0 {
+		values["invalid"] = strings.Join(o.Invalid, ",")
+	}
+
+	return values
+}
+
+// FormatFloat formats v with the fewest digits that represent it exactly.
+func FormatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// Clone returns a deep copy of the observation.
+func (o *Observation) Clone() *Observation {
+	c := *o
+	for _, f := range fields {
+		if p := *f.ptr(o); p != nil {
+			v := *p
+			*f.ptr(&c) = &v
+		}
+	}
+	if o.Extra != nil {
+		c.Extra = make(map[string]string, len(o.Extra))
+		for k, v := range o.Extra {
+			c.Extra[k] = v
+		}
+	}
+	c.Missing = append([]string(nil), o.Missing...)
+	c.Invalid = append([]string(nil), o.Invalid...)
+	return &c
+}
+
+// MeasurementKeys returns the Ecowitt keys of every sensor measurement,
+// leaving out battery levels and gateway bookkeeping such as runtime.
+func MeasurementKeys() []string {
+	var keys []string
+	for _, f := range fields {
+		switch {
+		case f.key == "runtime", f.key == "interval", f.key == "lightning_time":
+		case strings.Contains(f.key, "batt"), f.key == "ws90cap_volt":
+		default:
+			keys = append(keys, f.key)
+		}
+	}
+	return keys
+}
+
+// ValueKeys returns MeasurementKeys followed by DerivedKeys, every quantity
+// Get returns that sinks publish.
+func ValueKeys() []string {
+	return append(MeasurementKeys(), derivedKeys...)
+}
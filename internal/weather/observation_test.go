@@ -0,0 +1,137 @@
+package weather
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// completeReport returns every expected parameter of a WS2320 report.
+func completeReport() url.Values {
+	values := url.Values{}
+	values.Set("PASSKEY", "ABCDEF")
+	values.Set("stationtype", "EasyWeatherV1.6.6")
+	values.Set("model", "WS2900_V2.01.18")
+	values.Set("dateutc", "2026-01-02 03:04:05")
+	values.Set("tempinf", "70.2")
+	values.Set("humidityin", "40")
+	values.Set("baromrelin", "29.92")
+	values.Set("baromabsin", "29.80")
+	values.Set("tempf", "50")
+	values.Set("humidity", "80")
+	values.Set("winddir", "180")
+	values.Set("windspeedmph", "4.5")
+	values.Set("windgustmph", "9")
+	values.Set("solarradiation", "420.5")
+	values.Set("uv", "3")
+	values.Set("rainratein", "0")
+	values.Set("dailyrainin", "0.25")
+	return values
+}
+
+func TestParseEcowitt(t *testing.T) {
+	obs := ParseEcowitt(completeReport())
+
+	if want := time.Date(2026, time.January, 2, 3, 4, 5, 0, time.UTC); !obs.Time.Equal(want) {
+		t.Errorf("time = %v, want %v", obs.Time, want)
+	}
+	if obs.PassKey != "ABCDEF" || obs.StationType != "EasyWeatherV1.6.6" || obs.Model != "WS2900_V2.01.18" {
+		t.Errorf("passkey, station type, model = %q, %q, %q", obs.PassKey, obs.StationType, obs.Model)
+	}
+	if obs.TempF == nil || *obs.TempF != 50 || obs.RainRateIn == nil || *obs.RainRateIn != 0 {
+		t.Errorf("tempf, rainratein = %v, %v, want 50 and a zero rain rate", obs.TempF, obs.RainRateIn)
+	}
+	if len(obs.Missing) != 0 || len(obs.Invalid) != 0 || len(obs.Extra) != 0 {
+		t.Errorf("missing %v, invalid %v, extra %v, want none", obs.Missing, obs.Invalid, obs.Extra)
+	}
+}
+
+func TestParseEcowittValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		key, value  string
+		wantInvalid []string
+		wantMissing []string
+	}{
+		{"out of range", "tempf", "200", []string{"tempf"}, []string{"tempf"}},
+		{"below range", "humidity", "-1", []string{"humidity"}, []string{"humidity"}},
+		{"not a number", "windspeedmph", "fast", []string{"windspeedmph"}, []string{"windspeedmph"}},
+		{"not a finite number", "uv", "NaN", []string{"uv"}, []string{"uv"}},
+		{"empty", "solarradiation", "", nil, []string{"solarradiation"}},
+		{"at the bound", "winddir", "360", nil, nil},
+		{"below zero", "tempf", "-40", nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := completeReport()
+			values.Set(tt.key, tt.value)
+			obs := ParseEcowitt(values)
+
+			if !reflect.DeepEqual(obs.Invalid, tt.wantInvalid) {
+				t.Errorf("invalid = %v, want %v", obs.Invalid, tt.wantInvalid)
+			}
+			if !reflect.DeepEqual(obs.Missing, tt.wantMissing) {
+				t.Errorf("missing = %v, want %v", obs.Missing, tt.wantMissing)
+			}
+			if _, ok := obs.Get(tt.key); ok != (tt.wantMissing == nil) {
+				t.Errorf("%s present = %v, want %v", tt.key, ok, tt.wantMissing == nil)
+			}
+		})
+	}
+}
+
+func TestParseEcowittMissingSensors(t *testing.T) {
+	// The outdoor array has lost its link: only the gateway's own sensors
+	values := url.Values{}
+	values.Set("dateutc", "2026-01-02 03:04:05")
+	values.Set("tempinf", "70.2")
+	values.Set("humidityin", "40")
+	values.Set("baromrelin", "29.92")
+	values.Set("baromabsin", "29.80")
+	values.Set("wh80batt", "3.1")
+	values.Set("newsensor", "42")
+
+	obs := ParseEcowitt(values)
+	want := []string{"tempf", "humidity", "winddir", "windspeedmph", "windgustmph", "solarradiation", "uv", "rainratein", "dailyrainin"}
+	if !reflect.DeepEqual(obs.Missing, want) {
+		t.Errorf("missing = %v, want %v", obs.Missing, want)
+	}
+	if obs.TempF != nil || obs.Empty() {
+		t.Errorf("tempf = %v, empty = %v, want only the indoor readings", obs.TempF, obs.Empty())
+	}
+	if obs.Battery.WH80 == nil || *obs.Battery.WH80 != 3.1 {
+		t.Errorf("wh80batt = %v, want 3.1", obs.Battery.WH80)
+	}
+	if obs.Extra["newsensor"] != "42" {
+		t.Errorf("extra = %v, want the unknown parameter kept", obs.Extra)
+	}
+}
+
+func TestParseEcowittTime(t *testing.T) {
+	tests := []struct {
+		value       string
+		wantInvalid bool
+	}{
+		{"now", false},
+		{"", false},
+		{"yesterday", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			values := completeReport()
+			values.Set("dateutc", tt.value)
+			before := time.Now().UTC()
+			obs := ParseEcowitt(values)
+
+			if obs.Time.Before(before.Add(-time.Second)) || obs.Time.After(time.Now().Add(time.Second)) {
+				t.Errorf("time = %v, want the time of receipt", obs.Time)
+			}
+			if invalid := len(obs.Invalid) > 0; invalid != tt.wantInvalid {
+				t.Errorf("invalid = %v, want dateutc invalid %v", obs.Invalid, tt.wantInvalid)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package weather
+
+// Unit conversions between the imperial units Ecowitt reports and metric.
+
+func FahrenheitToCelsius(f float64) float64 { return (f - 32) * 5 / 9 }
+func CelsiusToFahrenheit(c float64) float64 { return c*9/5 + 32 }
+func MphToKmh(mph float64) float64          { return mph * 1.609344 }
+func MphToMs(mph float64) float64           { return mph * 0.44704 }
+func InHgToHPa(inHg float64) float64        { return inHg * 33.8639 }
+func InchesToMm(in float64) float64         { return in * 25.4 }
+
+// Metric is the observation converted to metric units.
+type Metric struct {
+	TempC          *float64 `json:"tempC,omitempty"`
+	IndoorTempC    *float64 `json:"indoorTempC,omitempty"`
+	Humidity       *float64 `json:"humidity,omitempty"`
+	IndoorHumidity *float64 `json:"indoorHumidity,omitempty"`
+	PressureHPa    *float64 `json:"pressureHPa,omitempty"`
+	AbsPressureHPa *float64 `json:"absPressureHPa,omitempty"`
+	WindDir        *float64 `json:"windDir,omitempty"`
+	WindSpeedKmh   *float64 `json:"windSpeedKmh,omitempty"`
+	WindGustKmh    *float64 `json:"windGustKmh,omitempty"`
+	SolarRadiation *float64 `json:"solarRadiation,omitempty"`
+	UV             *float64 `json:"uv,omitempty"`
+	RainRateMm     *float64 `json:"rainRateMm,omitempty"`
+	EventRainMm    *float64 `json:"eventRainMm,omitempty"`
+	HourlyRainMm   *float64 `json:"hourlyRainMm,omitempty"`
+	DailyRainMm    *float64 `json:"dailyRainMm,omitempty"`
+	WeeklyRainMm   *float64 `json:"weeklyRainMm,omitempty"`
+	MonthlyRainMm  *float64 `json:"monthlyRainMm,omitempty"`
+	YearlyRainMm   *float64 `json:"yearlyRainMm,omitempty"`
+}
+
+// Imperial is the observation in the units Wunderground and Ecowitt use.
+type Imperial struct {
+	TempF           *float64 `json:"tempF,omitempty"`
+	IndoorTempF     *float64 `json:"indoorTempF,omitempty"`
+	Humidity        *float64 `json:"humidity,omitempty"`
+	IndoorHumidity  *float64 `json:"indoorHumidity,omitempty"`
+	PressureInHg    *float64 `json:"pressureInHg,omitempty"`
+	AbsPressureInHg *float64 `json:"absPressureInHg,omitempty"`
+	WindDir         *float64 `json:"windDir,omitempty"`
+	WindSpeedMph    *float64 `json:"windSpeedMph,omitempty"`
+	WindGustMph     *float64 `json:"windGustMph,omitempty"`
+	SolarRadiation  *float64 `json:"solarRadiation,omitempty"`
+	UV              *float64 `json:"uv,omitempty"`
+	RainRateIn      *float64 `json:"rainRateIn,omitempty"`
+	EventRainIn     *float64 `json:"eventRainIn,omitempty"`
+	HourlyRainIn    *float64 `json:"hourlyRainIn,omitempty"`
+	DailyRainIn     *float64 `json:"dailyRainIn,omitempty"`
+	WeeklyRainIn    *float64 `json:"weeklyRainIn,omitempty"`
+	MonthlyRainIn   *float64 `json:"monthlyRainIn,omitempty"`
+	YearlyRainIn    *float64 `json:"yearlyRainIn,omitempty"`
+}
+
+// Metric returns the observation converted to metric units.
+func (o *Observation) Metric() Metric {
+	return Metric{
+		TempC:          convert(o.TempF, FahrenheitToCelsius),
+		IndoorTempC:    convert(o.IndoorTempF, FahrenheitToCelsius),
+		Humidity:       copyOf(o.Humidity),
+		IndoorHumidity: copyOf(o.IndoorHumidity),
+		PressureHPa:    convert(o.BaromRelIn, InHgToHPa),
+		AbsPressureHPa: convert(o.BaromAbsIn, InHgToHPa),
+		WindDir:        copyOf(o.WindDir),
+		WindSpeedKmh:   convert(o.WindSpeedMph, MphToKmh),
+		WindGustKmh:    convert(o.WindGustMph, MphToKmh),
+		SolarRadiation: copyOf(o.SolarRadiation),
+		UV:             copyOf(o.UV),
+		RainRateMm:     convert(o.RainRateIn, InchesToMm),
+		EventRainMm:    convert(o.EventRainIn, InchesToMm),
+		HourlyRainMm:   convert(o.HourlyRainIn, InchesToMm),
+		DailyRainMm:    convert(o.DailyRainIn, InchesToMm),
+		WeeklyRainMm:   convert(o.WeeklyRainIn, InchesToMm),
+		MonthlyRainMm:  convert(o.MonthlyRainIn, InchesToMm),
+		YearlyRainMm:   convert(o.YearlyRainIn, InchesToMm),
+	}
+}
+
+// Imperial returns the observation in imperial units.
+func (o *Observation) Imperial() Imperial {
+	return Imperial{
+		TempF:           copyOf(o.TempF),
+		IndoorTempF:     copyOf(o.IndoorTempF),
+		Humidity:        copyOf(o.Humidity),
+		IndoorHumidity:  copyOf(o.IndoorHumidity),
+		PressureInHg:    copyOf(o.BaromRelIn),
+		AbsPressureInHg: copyOf(o.BaromAbsIn),
+		WindDir:         copyOf(o.WindDir),
+		WindSpeedMph:    copyOf(o.WindSpeedMph),
+		WindGustMph:     copyOf(o.WindGustMph),
+		SolarRadiation:  copyOf(o.SolarRadiation),
+		UV:              copyOf(o.UV),
+		RainRateIn:      copyOf(o.RainRateIn),
+		EventRainIn:     copyOf(o.EventRainIn),
+		HourlyRainIn:    copyOf(o.HourlyRainIn),
+		DailyRainIn:     copyOf(o.DailyRainIn),
+		WeeklyRainIn:    copyOf(o.WeeklyRainIn),
+		MonthlyRainIn:   copyOf(o.MonthlyRainIn),
+		YearlyRainIn:    copyOf(o.YearlyRainIn),
+	}
+}
+
+func convert(p *float64, fn func(float64) float64) *float64 {
+	if p == nil {
+		return nil
+	}
+	v := fn(*p)
+	return &v
+}
+
+func copyOf(p *float64) *float64 {
+	if p == nil {
+		return nil
+	}
+	v := *p
+	return &v
+}
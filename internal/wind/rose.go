@@ -0,0 +1,112 @@
+package wind
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Sectors is the number of direction sectors of a wind rose.
+const Sectors = 16
+
+var sectorNames = [Sectors]string{
+	"N", "NNE", "NE", "ENE", "E", "ESE", "SE", "SSE",
+	"S", "SSW", "SW", "WSW", "W", "WNW", "NW", "NNW",
+}
+
+// DefaultClasses are the lower bounds, in m/s, of the speed classes of a
+// wind rose. Slower winds are counted as calm.
+var DefaultClasses = []float64{0.5, 2.1, 3.6, 5.7, 8.8, 11.1}
+
+// Sector returns the index of the 22.5° sector centred on dir, 0 being north.
+func Sector(dir float64) int {
+	dir = math.Mod(dir, 360)
+	if dir < 0 {
+		dir += 360
+	}
+	return int(math.Floor(dir/(360.0/Sectors)+0.5)) % Sectors
+}
+
+// SectorName returns the compass point of a sector, such as "NNE".
+func SectorName(sector int) string {
+	return sectorNames[sector%Sectors]
+}
+
+// Rose counts wind samples by direction sector and speed class.
+type Rose struct {
+	Classes []float64
+	Calm    int
+	Counts  [Sectors][]int
+	Total   int
+}
+
+// NewRose returns an empty rose with the given ascending speed classes. The
+// first class bound is the calm threshold.
+func NewRose(classes []float64) *Rose {
+	r := &Rose{Classes: classes}
+	for i := range r.Counts {
+		r.Counts[i] = make([]int, len(classes))
+	}
+	return r
+}
+
+// Add counts weight samples of speed (m/s) from direction dir. Averages over
+// an interval are weighted by the number of observations they stand for.
+func (r *Rose) Add(speedMs, dir float64, weight int) {
+	r.Total += weight
+	class := -1
+	for i, bound := range r.Classes {
+		if speedMs >= bound {
+			class = i
+		}
+	}
+	if class < 0 {
+		r.Calm += weight
+		return
+	}
+	r.Counts[Sector(dir)][class] += weight
+}
+
+// ClassName labels speed class i, such as "2.1-3.6" or "11.1+".
+func (r *Rose) ClassName(i int) string {
+	if i == len(r.Classes)-1 {
+		return fmt.Sprintf("%g+", r.Classes[i])
+	}
+	return fmt.Sprintf("%g-%g", r.Classes[i], r.Classes[i+1])
+}
+
+// Prevailing returns the sector the wind blew from most often, or -1 if it
+// was always calm.
+func (r *Rose) Prevailing() int {
+	best, most := -1, 0
+	for sector, counts := range r.Counts {
+		n := 0
+		for _, c := range counts {
+			n += c
+		}
+		if n > most {
+			best, most = sector, n
+		}
+	}
+	return best
+}
+
+// ParseClasses parses a comma-separated list of ascending class bounds.
+func ParseClasses(s string) ([]float64, error) {
+	var classes []float64
+	for _, field := range strings.Split(s, ",") {
+		v, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid speed class %q", field)
+		}
+		if n := len(classes); n > 0 && v <= classes[n-1] {
+			return nil, fmt.Errorf("speed classes must be ascending")
+		}
+		classes = append(classes, v)
+	}
+	if len(classes) == 0 {
+		return nil, fmt.Errorf("no speed classes")
+	}
+	return classes, nil
+}
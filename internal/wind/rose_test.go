@@ -0,0 +1,85 @@
+package wind
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSector(t *testing.T) {
+	tests := []struct {
+		dir  float64
+		want int
+		name string
+	}{
+		{0, 0, "N"},
+		{11.24, 0, "N"},
+		{11.25, 1, "NNE"},
+		{90, 4, "E"},
+		{180, 8, "S"},
+		{348.74, 15, "NNW"},
+		{348.75, 0, "N"},
+		{360, 0, "N"},
+		{-22.5, 15, "NNW"},
+		{405, 2, "NE"},
+	}
+	for _, tt := range tests {
+		got := Sector(tt.dir)
+		if got != tt.want || SectorName(got) != tt.name {
+			t.Errorf("Sector(%v) = %d %s, want %d %s", tt.dir, got, SectorName(got), tt.want, tt.name)
+		}
+	}
+}
+
+func TestRose(t *testing.T) {
+	r := NewRose([]float64{0.5, 2, 5})
+	r.Add(0.3, 90, 4)  // calm
+	r.Add(0.5, 0, 1)   // lowest class, north
+	r.Add(3, 355, 2)   // second class, north
+	r.Add(7, 180, 1)   // top class, south
+	r.Add(1.9, 200, 3) // lowest class, south-southwest
+
+	if r.Total != 11 || r.Calm != 4 {
+		t.Errorf("total, calm = %d, %d, want 11, 4", r.Total, r.Calm)
+	}
+	want := map[int][]int{0: {1, 2, 0}, 8: {0, 0, 1}, 9: {3, 0, 0}}
+	for sector, counts := range r.Counts {
+		expected := want[sector]
+		if expected == nil {
+			expected = []int{0, 0, 0}
+		}
+		if !reflect.DeepEqual(counts, expected) {
+			t.Errorf("%s counts = %v, want %v", SectorName(sector), counts, expected)
+		}
+	}
+	if got := r.Prevailing(); got != 0 {
+		t.Errorf("prevailing = %s, want N, the first of the tied sectors", SectorName(got))
+	}
+	r.Add(1, 200, 1)
+	if got := r.Prevailing(); got != 9 {
+		t.Errorf("prevailing = %s, want SSW", SectorName(got))
+	}
+
+	if got := []string{r.ClassName(0), r.ClassName(2)}; !reflect.DeepEqual(got, []string{"0.5-2", "5+"}) {
+		t.Errorf("class names = %v", got)
+	}
+}
+
+func TestRoseCalm(t *testing.T) {
+	r := NewRose(DefaultClasses)
+	r.Add(0.1, 45, 10)
+	if got := r.Prevailing(); got != -1 {
+		t.Errorf("prevailing = %d, want -1 when always calm", got)
+	}
+}
+
+func TestParseClasses(t *testing.T) {
+	got, err := ParseClasses(" 0.5, 2.1 ,5")
+	if err != nil || !reflect.DeepEqual(got, []float64{0.5, 2.1, 5}) {
+		t.Errorf("ParseClasses = %v, %v", got, err)
+	}
+	for _, s := range []string{"", "1,x", "1,1", "2,1"} {
+		if _, err := ParseClasses(s); err == nil {
+			t.Errorf("ParseClasses(%q) succeeded", s)
+		}
+	}
+}
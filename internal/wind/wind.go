@@ -0,0 +1,146 @@
+// Package wind computes wind statistics from the gateway's observations:
+// vector averages, direction variability, gust factor, the Beaufort force
+// and wind roses.
+package wind
+
+import (
+	"math"
+	"time"
+	"wsrepeater/internal/weather"
+)
+
+const (
+	// CalmMs is the speed below which the wind has no direction (Beaufort 0).
+	CalmMs = 0.5
+	// gustFactorMinMs is the least mean speed a gust factor is given for;
+	// at lighter winds it is dominated by noise.
+	gustFactorMinMs = 2.0
+)
+
+// Summary describes the wind over a period. Speeds are in m/s and
+// directions in degrees; Direction and Variability are nil when it was calm.
+type Summary struct {
+	From        time.Time `json:"from"`
+	To          time.Time `json:"to"`
+	Samples     int       `json:"samples"`
+	SpeedMs     float64   `json:"speedMs"`
+	VectorMs    float64   `json:"vectorMs"`
+	Direction   *float64  `json:"direction"`
+	Cardinal    string    `json:"cardinal,omitempty"`
+	Variability *float64  `json:"variability"`
+	GustMs      *float64  `json:"gustMs"`
+	GustFactor  *float64  `json:"gustFactor"`
+	Beaufort    int       `json:"beaufort"`
+	Description string    `json:"description"`
+}
+
+// Summarize averages the observations with from < time <= to. The speed is
+// the scalar mean, the direction the speed-weighted vector mean, and the
+// variability the standard deviation of direction after Yamartino (1984),
+// computed from the samples that weren't calm.
+func Summarize(observations []*weather.Observation, from, to time.Time) Summary {
+	s := Summary{From: from, To: to}
+
+	var sum, u, v, unitU, unitV float64
+	var directions int
+	for _, obs := range observations {
+		if !obs.Time.After(from) || obs.Time.After(to) || obs.WindSpeedMph == nil {
+			continue
+		}
+		speed := weather.MphToMs(*obs.WindSpeedMph)
+		sum += speed
+		s.Samples++
+
+		if obs.WindGustMph != nil {
+			gust := weather.MphToMs(*obs.WindGustMph)
+			if s.GustMs == nil || gust > *s.GustMs {
+				s.GustMs = &gust
+			}
+		}
+
+		if obs.WindDir == nil || speed < CalmMs {
+			continue
+		}
+		rad := *obs.WindDir * math.Pi / 180
+		u += speed * math.Sin(rad)
+		v += speed * math.Cos(rad)
+		unitU += math.Sin(rad)
+		unitV += math.Cos(rad)
+		directions++
+	}
+	if s.Samples == 0 {
+		return s
+	}
+
+	s.SpeedMs = sum / float64(s.Samples)
+	s.VectorMs = math.Hypot(u, v) / float64(s.Samples)
+	s.Beaufort = Beaufort(s.SpeedMs)
+	s.Description = BeaufortDescription(s.Beaufort)
+
+	if directions > 0 && s.SpeedMs >= CalmMs {
+		dir := math.Mod(math.Atan2(u, v)*180/math.Pi+360, 360)
+		s.Direction = &dir
+		s.Cardinal = SectorName(Sector(dir))
+
+		if directions > 1 {
+			sa, ca := unitU/float64(directions), unitV/float64(directions)
+			eps := math.Sqrt(math.Max(0, 1-(sa*sa+ca*ca)))
+			sigma := math.Asin(eps) * (1 + (2/math.Sqrt(3)-1)*math.Pow(eps, 3)) * 180 / math.Pi
+			s.Variability = &sigma
+		}
+	}
+
+	if s.GustMs != nil {
+		if factor, ok := GustFactor(*s.GustMs, s.SpeedMs); ok {
+			s.GustFactor = &factor
+		}
+	}
+
+	return s
+}
+
+// GustFactor returns the ratio of the peak gust to the mean speed, if the
+// wind was strong enough for it to be meaningful.
+func GustFactor(gustMs, meanMs float64) (float64, bool) {
+	if meanMs < gustFactorMinMs {
+		return 0, false
+	}
+	return gustMs / meanMs, true
+}
+
+// beaufortLimits are the upper bounds, in m/s, of forces 0 to 11.
+var beaufortLimits = []float64{0.5, 1.6, 3.4, 5.5, 8.0, 10.8, 13.9, 17.2, 20.8, 24.5, 28.5, 32.7}
+
+var beaufortDescriptions = []string{
+	"Calm",
+	"Light air",
+	"Light breeze",
+	"Gentle breeze",
+	"Moderate breeze",
+	"Fresh breeze",
+	"Strong breeze",
+	"Near gale",
+	"Gale",
+	"Strong gale",
+	"Storm",
+	"Violent storm",
+	"Hurricane force",
+}
+
+// Beaufort returns the Beaufort force of a wind speed in m/s.
+func Beaufort(speedMs float64) int {
+	for force, limit := range beaufortLimits {
+		if speedMs < limit {
+			return force
+		}
+	}
+	return len(beaufortLimits)
+}
+
+// BeaufortDescription returns the name of a Beaufort force.
+func BeaufortDescription(force int) string {
+	if force < 0 || force >= len(beaufortDescriptions) {
+		return ""
+	}
+	return beaufortDescriptions[force]
+}
@@ -0,0 +1,185 @@
+package wind
+
+import (
+	"math"
+	"testing"
+	"time"
+	"wsrepeater/internal/weather"
+)
+
+var start = time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+// sample returns an observation i minutes after start with a speed in m/s.
+func sample(i int, speedMs, dir float64) *weather.Observation {
+	mph := speedMs / 0.44704
+	return &weather.Observation{Time: start.Add(time.Duration(i) * time.Minute), WindSpeedMph: &mph, WindDir: &dir}
+}
+
+func withGust(obs *weather.Observation, gustMs float64) *weather.Observation {
+	mph := gustMs / 0.44704
+	obs.WindGustMph = &mph
+	return obs
+}
+
+func near(got, want float64) bool {
+	return math.Abs(got-want) < 1e-6
+}
+
+func TestSummarizeDirection(t *testing.T) {
+	tests := []struct {
+		name            string
+		observations    []*weather.Observation
+		wantDirection   float64
+		wantVector      float64
+		wantVariability float64
+	}{
+		{
+			name:            "across north",
+			observations:    []*weather.Observation{sample(1, 5, 350), sample(2, 5, 10)},
+			wantDirection:   0,
+			wantVector:      5 * math.Cos(10*math.Pi/180),
+			wantVariability: 10 * (1 + (2/math.Sqrt(3)-1)*math.Pow(math.Sin(10*math.Pi/180), 3)),
+		},
+		{
+			name:          "steady",
+			observations:  []*weather.Observation{sample(1, 3, 270), sample(2, 4, 270), sample(3, 5, 270)},
+			wantDirection: 270,
+			wantVector:    4,
+		},
+		{
+			name:            "weighted by speed",
+			observations:    []*weather.Observation{sample(1, 9, 90), sample(2, 1, 180)},
+			wantDirection:   math.Atan2(9, -1) * 180 / math.Pi,
+			wantVector:      math.Hypot(9, 1) / 2,
+			wantVariability: 45 * (1 + (2/math.Sqrt(3)-1)*math.Pow(math.Sin(45*math.Pi/180), 3)),
+		},
+		{
+			name:            "calm sample ignored",
+			observations:    []*weather.Observation{sample(1, 4, 180), sample(2, 0.2, 0), sample(3, 4, 180)},
+			wantDirection:   180,
+			wantVector:      8.0 / 3,
+			wantVariability: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Summarize(tt.observations, start, start.Add(time.Hour))
+			if s.Direction == nil {
+				t.Fatal("direction is nil")
+			}
+			diff := math.Mod(*s.Direction-tt.wantDirection+540, 360) - 180
+			if math.Abs(diff) > 1e-6 {
+				t.Errorf("direction = %v, want %v", *s.Direction, tt.wantDirection)
+			}
+			if !near(s.VectorMs, tt.wantVector) {
+				t.Errorf("vector speed = %v, want %v", s.VectorMs, tt.wantVector)
+			}
+			if s.Variability == nil || !near(*s.Variability, tt.wantVariability) {
+				t.Errorf("variability = %v, want %v", s.Variability, tt.wantVariability)
+			}
+		})
+	}
+}
+
+func TestSummarizeVariabilityGrowsWithSpread(t *testing.T) {
+	var last float64
+	for _, spread := range []float64{0, 10, 30, 60, 90} {
+		s := Summarize([]*weather.Observation{
+			sample(1, 5, 180-spread),
+			sample(2, 5, 180),
+			sample(3, 5, 180+spread),
+		}, start, start.Add(time.Hour))
+		if s.Variability == nil {
+			t.Fatalf("spread %v: variability is nil", spread)
+		}
+		if spread == 0 && *s.Variability > 1e-6 {
+			t.Errorf("constant direction: variability = %v, want 0", *s.Variability)
+		}
+		if spread > 0 && *s.Variability <= last {
+			t.Errorf("spread %v: variability = %v, not above %v", spread, *s.Variability, last)
+		}
+		last = *s.Variability
+	}
+}
+
+func TestSummarizeCalm(t *testing.T) {
+	s := Summarize([]*weather.Observation{
+		withGust(sample(1, 0.2, 90), 0.9),
+		sample(2, 0.4, 270),
+	}, start, start.Add(time.Hour))
+
+	if s.Samples != 2 || !near(s.SpeedMs, 0.3) {
+		t.Errorf("samples, speed = %d, %v, want 2, 0.3", s.Samples, s.SpeedMs)
+	}
+	if s.Direction != nil || s.Variability != nil || s.Cardinal != "" {
+		t.Errorf("direction, variability, cardinal = %v, %v, %q, want none when calm", s.Direction, s.Variability, s.Cardinal)
+	}
+	if s.Beaufort != 0 || s.Description != "Calm" {
+		t.Errorf("beaufort = %d %q, want 0 Calm", s.Beaufort, s.Description)
+	}
+	if s.GustMs == nil || !near(*s.GustMs, 0.9) || s.GustFactor != nil {
+		t.Errorf("gust, gust factor = %v, %v, want 0.9 and no factor", s.GustMs, s.GustFactor)
+	}
+}
+
+func TestSummarizeWindow(t *testing.T) {
+	observations := []*weather.Observation{
+		sample(0, 10, 0),
+		withGust(sample(30, 4, 0), 6),
+		withGust(sample(60, 4, 0), 5),
+		sample(61, 10, 0),
+	}
+	s := Summarize(observations, start, start.Add(time.Hour))
+
+	if s.Samples != 2 || !near(s.SpeedMs, 4) {
+		t.Errorf("samples, speed = %d, %v, want only the two within (from, to]", s.Samples, s.SpeedMs)
+	}
+	if s.GustFactor == nil || !near(*s.GustFactor, 1.5) {
+		t.Errorf("gust factor = %v, want 1.5", s.GustFactor)
+	}
+	if s.Cardinal != "N" || s.Beaufort != 3 {
+		t.Errorf("cardinal, beaufort = %q, %d, want N, 3", s.Cardinal, s.Beaufort)
+	}
+
+	if empty := Summarize(observations, start.Add(2*time.Hour), start.Add(3*time.Hour)); empty.Samples != 0 || empty.Description != "" {
+		t.Errorf("empty window = %+v", empty)
+	}
+}
+
+func TestGustFactor(t *testing.T) {
+	if _, ok := GustFactor(5, 1.9); ok {
+		t.Error("gust factor given below 2 m/s")
+	}
+	if f, ok := GustFactor(5, 2); !ok || f != 2.5 {
+		t.Errorf("GustFactor(5, 2) = %v, %v, want 2.5", f, ok)
+	}
+}
+
+func TestBeaufort(t *testing.T) {
+	tests := []struct {
+		speedMs float64
+		want    int
+	}{
+		{0, 0},
+		{0.49, 0},
+		{0.5, 1},
+		{3.39, 2},
+		{3.4, 3},
+		{10.8, 6},
+		{32.69, 11},
+		{32.7, 12},
+		{60, 12},
+	}
+	for _, tt := range tests {
+		if got := Beaufort(tt.speedMs); got != tt.want {
+			t.Errorf("Beaufort(%v) = %d, want %d", tt.speedMs, got, tt.want)
+		}
+	}
+	if got := BeaufortDescription(12); got != "Hurricane force" {
+		t.Errorf("BeaufortDescription(12) = %q", got)
+	}
+	if got := BeaufortDescription(13); got != "" {
+		t.Errorf("BeaufortDescription(13) = %q, want empty", got)
+	}
+}